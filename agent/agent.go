@@ -3,28 +3,61 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"systeminfoagent/collector"
 	"systeminfoagent/model"
+	"systeminfoagent/tlsutil"
 	"time"
 )
 
 type Config struct {
 	NodeID     string
 	MasterAddr string
+	// CAFile 校验 master 证书使用的 CA，为空时使用系统的 CA
+	CAFile string
+	// CertFile、KeyFile 提供给 master 的客户端证书，文件被修改后会自动重新加载
+	CertFile string
+	KeyFile  string
+	// TokenFile 保存上报数据使用的 bearer token，每次上报时重新读取
+	TokenFile string
+	// Collector 采集方式
+	Collector collector.Options
 }
 
 func main() {
-	config := &Config{
-		// NodeID: fmt.Sprintf("%d", time.Now().UnixNano()),
-		// NodeID:     "aa",
-		NodeID:     os.Args[1],
-		MasterAddr: "http://10.211.55.52:8080",
+	config := &Config{}
+	flag.StringVar(&config.MasterAddr, "master", "http://10.211.55.52:8080", "address of the master, use https:// to enable tls")
+	flag.StringVar(&config.CAFile, "ca", "", "ca bundle to verify the master certificate")
+	flag.StringVar(&config.CertFile, "cert", "", "client certificate presented to the master")
+	flag.StringVar(&config.KeyFile, "key", "", "private key of the client certificate")
+	flag.StringVar(&config.TokenFile, "token-file", "", "file containing the bearer token for the master")
+	flag.BoolVar(&config.Collector.PerCore, "per-core", false, "also report the utilization of each cpu")
+	flag.StringVar(&config.Collector.NetInterface, "net-interface", "", "network interface to report, defaults to the one of the default route")
+	flag.StringVar(&config.Collector.DiskDevice, "disk-device", "", "block device to report reads and writes of, as named in /proc/diskstats, defaults to the one mounted at /")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: agent [flags] <nodeid>")
+		os.Exit(2)
 	}
-	c := collector.NewDefaultCollector()
+	config.NodeID = flag.Arg(0)
+
+	c := collector.NewDefaultCollector(config.Collector)
 	httpClient := http.DefaultClient
+	if strings.HasPrefix(config.MasterAddr, "https://") {
+		tlsConfig, err := tlsutil.ClientConfig(config.CAFile, config.CertFile, config.KeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		httpClient = &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		}
+	}
 	metricURL := config.MasterAddr + "/api/v1/agenthealth/" + config.NodeID
 	for {
 		time.Sleep(time.Second)
@@ -45,11 +78,22 @@ func main() {
 			log.Println("[err] gen http request:", err)
 			continue
 		}
+		if config.TokenFile != "" {
+			token, err := os.ReadFile(config.TokenFile)
+			if err != nil {
+				log.Println("[err] read token:", err)
+				continue
+			}
+			req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+		}
 		resp, err := httpClient.Do(req)
 		if err != nil {
 			log.Println(err)
 			continue
 		}
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			log.Println("[err] master rejected metric:", resp.Status)
+		}
 		_ = resp.Body.Close()
 	}
 }
@@ -3,15 +3,15 @@ package collector
 import (
 	"fmt"
 	"log"
+	"path/filepath"
+	"strings"
 	"sync"
 	"systeminfoagent/diskusage"
 	"systeminfoagent/model"
 	"time"
 
 	"github.com/mackerelio/go-osstat/cpu"
-	"github.com/mackerelio/go-osstat/disk"
 	"github.com/mackerelio/go-osstat/memory"
-	"github.com/mackerelio/go-osstat/network"
 )
 
 // Collector 用于 agent 在节点上收集系统的相关信息
@@ -23,9 +23,19 @@ type DefaultCollector struct {
 	collectors []Collector
 }
 
-func NewDefaultCollector() *DefaultCollector {
+// Options agent 可以调整的采集方式
+type Options struct {
+	// PerCore 同时采集每个 CPU 的使用率
+	PerCore bool
+	// NetInterface 采集流量的网卡，为空时使用默认路由所在的网卡
+	NetInterface string
+	// DiskDevice 采集读写次数的块设备（/proc/diskstats 中的名字），为空时使用 / 所在的设备
+	DiskDevice string
+}
+
+func NewDefaultCollector(options Options) *DefaultCollector {
 	return &DefaultCollector{
-		collectors: []Collector{&CPUCollector{}, &MemoryCollector{}, &DiskCollector{}, &NetCollector{}},
+		collectors: []Collector{&CPUCollector{PerCore: options.PerCore}, &MemoryCollector{}, &DiskCollector{Device: options.DiskDevice}, &NetCollector{Interface: options.NetInterface}, &LoadCollector{}, &PressureCollector{}, &SocketCollector{}},
 	}
 }
 
@@ -45,23 +55,50 @@ func (dc *DefaultCollector) Collect(metric *model.NodeMetric) error {
 	return nil
 }
 
-type CPUCollector struct{}
+type CPUCollector struct {
+	// PerCore 为 true 时在 model.CPU.PerCore 中给出每个 CPU 的使用率
+	PerCore bool
+}
 
-func (*CPUCollector) Collect(metric *model.NodeMetric) error {
+func (cc *CPUCollector) Collect(metric *model.NodeMetric) error {
 	before, err := cpu.Get()
 	if err != nil {
 		return fmt.Errorf("get cpu info: %v", err)
 	}
+	// 单个 CPU 的数据读取失败或者 CPU 上下线时只是不给出 PerCore，整体的数据仍然上报
+	var coresBefore []coreStat
+	var coresErr error
+	if cc.PerCore {
+		coresBefore, coresErr = readCoreStats()
+	}
 	time.Sleep(time.Duration(1) * time.Second)
 	after, err := cpu.Get()
 	if err != nil {
 		return fmt.Errorf("get cpu info: %v", err)
 	}
+	var perCore *model.PerCore
+	if cc.PerCore && coresErr == nil {
+		var coresAfter []coreStat
+		if coresAfter, coresErr = readCoreStats(); coresErr == nil {
+			perCore, coresErr = summarizeCores(coresBefore, coresAfter)
+		}
+	}
+	if coresErr != nil {
+		log.Println("[warn] get per-cpu info:", coresErr)
+	}
 	metric.CPU = model.CPU{
-		Valid:  true,
-		User:   after.User - before.User,
-		System: after.System - before.System,
-		Idle:   after.Idle - before.Idle,
+		Valid:     true,
+		User:      after.User - before.User,
+		System:    after.System - before.System,
+		Idle:      after.Idle - before.Idle,
+		Nice:      after.Nice - before.Nice,
+		IOWait:    counterDelta(after.Iowait, before.Iowait), // iowait 的统计并不单调，可能比之前的值小
+		IRQ:       after.Irq - before.Irq,
+		SoftIRQ:   after.Softirq - before.Softirq,
+		Steal:     after.Steal - before.Steal,
+		Guest:     after.Guest - before.Guest,
+		GuestNice: after.GuestNice - before.GuestNice,
+		PerCore:   perCore,
 	}
 	return nil
 }
@@ -74,40 +111,87 @@ func (*MemoryCollector) Collect(metric *model.NodeMetric) error {
 		return fmt.Errorf("get memory info: %v", err)
 	}
 	metric.Memory = model.Memory{
-		Valid:  true,
-		Total:  memory.Total,
-		Used:   memory.Used,
-		Cached: memory.Cached,
-		Free:   memory.Free,
+		Valid:     true,
+		Total:     memory.Total,
+		Used:      memory.Used,
+		Cached:    memory.Cached,
+		Free:      memory.Free,
+		Buffers:   memory.Buffers,
+		SwapTotal: memory.SwapTotal,
+		SwapUsed:  memory.SwapUsed,
+	}
+	if memory.MemAvailableEnabled {
+		metric.Memory.Available = memory.Available
+	}
+	// 换入换出只用于 swap 惩罚，读取失败时仍然上报内存，SwapIn 和 SwapOut 为 0
+	if err := collectSwapActivity(&metric.Memory); err != nil {
+		log.Println("[warn] get swap activity:", err)
+	}
+	return nil
+}
+
+// collectSwapActivity 间隔一秒读取两次 /proc/vmstat，计算每秒换入换出的页数
+func collectSwapActivity(memory *model.Memory) error {
+	before, err := readSwapCounters()
+	if err != nil {
+		return err
+	}
+	time.Sleep(time.Duration(1) * time.Second)
+	after, err := readSwapCounters()
+	if err != nil {
+		return err
 	}
+	memory.SwapIn = counterDelta(after.in, before.in)
+	memory.SwapOut = counterDelta(after.out, before.out)
 	return nil
 }
 
-type DiskCollector struct{}
+// DiskCollector Device 为空时使用挂载在 / 的设备
+type DiskCollector struct {
+	Device string
+}
 
-func (*DiskCollector) Collect(metric *model.NodeMetric) error {
-	disks, err := disk.Get()
+func (c *DiskCollector) Collect(metric *model.NodeMetric) error {
+	before, err := readDiskStats()
 	if err != nil {
 		return fmt.Errorf("get diskinfo: %v", err)
 	}
-	var beforeR, beforeW, flag uint64
-	for _, di := range disks {
-		if di.Name == "sda" { // TODO: set as arg
-			beforeR, beforeW, flag = di.ReadsCompleted, di.WritesCompleted, 1
-		}
+	time.Sleep(time.Duration(1) * time.Second)
+	after, err := readDiskStats()
+	if err != nil {
+		return fmt.Errorf("get diskinfo: %v", err)
 	}
-	if flag == 0 {
-		return fmt.Errorf("get diskinfo: sda not found")
+	// 各个设备的 I/O 情况不依赖于 / 所在的设备是否找得到
+	devices := devicesIO(before, after)
+	mounts, err := diskusage.Mounts()
+	if err != nil {
+		log.Println("[err] get mounts:", err)
 	}
-	time.Sleep(time.Duration(1) * time.Second)
-	var afterR, afterW uint64
-	disks, _ = disk.Get()
-	for _, di := range disks {
-		if di.Name == "sda" {
-			afterR, afterW = di.ReadsCompleted, di.WritesCompleted
+	device := c.Device
+	if device == "" {
+		device = diskStatName(mountDevice(mounts, "/"))
+	}
+	// 找不到设备时读写次数为 0，空间和 inode 的使用情况仍然上报
+	var beforeR, beforeW, afterR, afterW uint64
+	var found bool
+	for _, di := range before {
+		if di.name == device {
+			beforeR, beforeW, found = di.reads, di.writes, true
+		}
+	}
+	for _, di := range after {
+		if di.name == device {
+			afterR, afterW = di.reads, di.writes
 		}
 	}
-	usage := diskusage.NewDiskUsage("/")
+	if !found {
+		log.Printf("[warn] get diskinfo: device %q not found in /proc/diskstats", device)
+	}
+	usage, err := diskusage.NewDiskUsage("/")
+	if err != nil {
+		metric.Disk = model.Disk{Devices: devices}
+		return fmt.Errorf("get diskinfo: %v", err)
+	}
 	metric.Disk = model.Disk{
 		Valid:      true,
 		Size:       usage.Size(),
@@ -115,41 +199,165 @@ func (*DiskCollector) Collect(metric *model.NodeMetric) error {
 		Free:       usage.Free(),
 		WriteTimes: afterW - beforeW,
 		ReadTimes:  afterR - beforeR,
+		Inodes:     usage.Inodes(),
+		InodesUsed: usage.UsedInodes(),
+		InodesFree: usage.FreeInodes(),
+		Available:  usage.Available(),
+		FsType:     usage.TypeName(),
+		ReadOnly:   usage.ReadOnly(),
+		Mounts:     mountsUsage(mounts),
+		Devices:    devices,
 	}
 	return nil
 }
 
-type NetCollector struct{}
+// mountDevice 返回挂载在 path 的设备，例如 /dev/nvme0n1p1，没有挂载时返回空字符串
+// 同一个路径被挂载多次时，后面的挂载覆盖前面的
+func mountDevice(mounts []diskusage.Mount, path string) string {
+	var device string
+	for _, m := range mounts {
+		if m.Path == path {
+			device = m.Device
+		}
+	}
+	return device
+}
 
-func (*NetCollector) Collect(metric *model.NodeMetric) error {
-	var (
-		mainEthStatBefore network.Stats
-		mainEthStatAfter  network.Stats
-		mainEthName       = "enp0s5" // TODO: set as arg
-	)
-	before, err := network.Get()
-	if err != nil {
-		return fmt.Errorf("get net: %v", err)
+// diskStatName 将挂载表中的设备路径转换为 /proc/diskstats 中的名字
+// /dev/mapper/vg-root 等符号链接解析为 dm-0，解析失败时使用路径的最后一段
+func diskStatName(device string) string {
+	if !strings.HasPrefix(device, "/dev/") {
+		return ""
 	}
-	for _, network := range before {
-		if network.Name == mainEthName {
-			mainEthStatBefore = network
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	return filepath.Base(device)
+}
+
+// mountsUsage 所有存储数据的挂载点的使用情况，单个挂载点出错时跳过
+// 网络文件系统（nfs、cifs 等）的服务端无响应时 statfs 会一直阻塞，这些挂载点在超时之后跳过
+func mountsUsage(mounts []diskusage.Mount) []model.MountUsage {
+	res := make([]model.MountUsage, 0, len(mounts))
+	for _, m := range mounts {
+		var usage *diskusage.DiskUsage
+		var err error
+		if m.Network() {
+			usage, err = diskUsageWithTimeout(m.Path, statfsTimeout)
+		} else {
+			usage, err = newDiskUsage(m.Path)
+		}
+		if err != nil {
+			log.Println("[err] get mount usage:", err)
+			continue
 		}
+		res = append(res, model.MountUsage{
+			Path:       m.Path,
+			Device:     m.Device,
+			FsType:     m.FsType,
+			ReadOnly:   m.ReadOnly || usage.ReadOnly(),
+			Size:       usage.Size(),
+			Used:       usage.Used(),
+			Free:       usage.Free(),
+			Available:  usage.Available(),
+			Inodes:     usage.Inodes(),
+			InodesFree: usage.FreeInodes(),
+		})
+	}
+	return res
+}
+
+// statfsTimeout 网络文件系统 statfs 的超时时间
+var statfsTimeout = 2 * time.Second
+
+// newDiskUsage 测试时替换
+var newDiskUsage = diskusage.NewDiskUsage
+
+var (
+	pendingLock sync.Mutex
+	// pendingStatfs 超时之后仍然没有返回的 statfs，返回之前不再对同一个挂载点调用，避免阻塞的 goroutine 越积越多
+	pendingStatfs = map[string]bool{}
+)
+
+// diskUsageWithTimeout 在单独的 goroutine 中调用 statfs，超过 timeout 时返回错误
+func diskUsageWithTimeout(path string, timeout time.Duration) (*diskusage.DiskUsage, error) {
+	pendingLock.Lock()
+	if pendingStatfs[path] {
+		pendingLock.Unlock()
+		return nil, fmt.Errorf("statfs %s: previous call has not returned", path)
+	}
+	pendingStatfs[path] = true
+	pendingLock.Unlock()
+
+	type result struct {
+		usage *diskusage.DiskUsage
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		usage, err := newDiskUsage(path)
+		pendingLock.Lock()
+		delete(pendingStatfs, path)
+		pendingLock.Unlock()
+		ch <- result{usage, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.usage, r.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("statfs %s: timed out after %v", path, timeout)
+	}
+}
+
+type NetCollector struct {
+	// Interface 采集的网卡，为空时每次采集前从 /proc/net/route 中查找默认路由所在的网卡
+	Interface string
+}
+
+func (nc *NetCollector) Collect(metric *model.NodeMetric) error {
+	name := nc.Interface
+	if name == "" {
+		var err error
+		if name, err = defaultRouteInterface(); err != nil {
+			metric.Network = model.Network{}
+			return fmt.Errorf("get net: %v", err)
+		}
+	}
+	before, err := readNetDevStats()
+	if err != nil {
+		return fmt.Errorf("get net: %v", err)
 	}
 	time.Sleep(time.Second)
-	after, err := network.Get()
+	after, err := readNetDevStats()
 	if err != nil {
 		return fmt.Errorf("get net: %v", err)
 	}
-	for _, network := range after {
-		if network.Name == mainEthName {
-			mainEthStatAfter = network
-		}
+	// 网卡不存在时不能上报全 0 的数据，否则 master 会认为这个节点的网络完全空闲
+	prev, ok := before[name]
+	curr, ok2 := after[name]
+	if !ok || !ok2 {
+		metric.Network = model.Network{}
+		return fmt.Errorf("get net: interface %s not found in /proc/net/dev", name)
 	}
 	metric.Network = model.Network{
-		Valid:   true,
-		RxBytes: mainEthStatAfter.RxBytes - mainEthStatBefore.RxBytes,
-		TxBytes: mainEthStatAfter.TxBytes - mainEthStatBefore.TxBytes,
+		Valid:     true,
+		RxBytes:   counterDelta(curr.rxBytes, prev.rxBytes),
+		TxBytes:   counterDelta(curr.txBytes, prev.txBytes),
+		RxPackets: counterDelta(curr.rxPackets, prev.rxPackets),
+		TxPackets: counterDelta(curr.txPackets, prev.txPackets),
+		RxErrors:  counterDelta(curr.rxErrors, prev.rxErrors),
+		TxErrors:  counterDelta(curr.txErrors, prev.txErrors),
+		RxDropped: counterDelta(curr.rxDropped, prev.rxDropped),
+		TxDropped: counterDelta(curr.txDropped, prev.txDropped),
+		SpeedMbps: linkSpeed(name),
 	}
 	return nil
 }
+
+// counterDelta 计数器被重置时返回 0
+func counterDelta(after, before uint64) uint64 {
+	if after < before {
+		return 0
+	}
+	return after - before
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"systeminfoagent/diskusage"
+)
+
+func TestDiskUsageWithTimeout(t *testing.T) {
+	release := make(chan struct{})
+	var calls int32
+	newDiskUsage = func(path string) (*diskusage.DiskUsage, error) {
+		atomic.AddInt32(&calls, 1)
+		if path == "/mnt/hung" {
+			<-release
+		}
+		return nil, errors.New("statfs " + path)
+	}
+	defer func() { newDiskUsage = diskusage.NewDiskUsage }()
+
+	if _, err := diskUsageWithTimeout("/mnt/ok", time.Second); err == nil || err.Error() != "statfs /mnt/ok" {
+		t.Errorf("error of the underlying call should be returned, got %v", err)
+	}
+	if _, err := diskUsageWithTimeout("/mnt/hung", 10*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	// 上一次调用返回之前不再调用
+	if _, err := diskUsageWithTimeout("/mnt/hung", 10*time.Millisecond); err == nil || !strings.Contains(err.Error(), "has not returned") {
+		t.Errorf("expected the pending call to be reported, got %v", err)
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 2 {
+		t.Errorf("statfs was called %d times, want 2", calls)
+	}
+	close(release)
+	for i := 0; i < 100; i++ {
+		pendingLock.Lock()
+		pending := pendingStatfs["/mnt/hung"]
+		pendingLock.Unlock()
+		if !pending {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("pending statfs was not cleared after it returned")
+}
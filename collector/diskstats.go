@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"systeminfoagent/model"
+	"time"
+)
+
+// sectorSize /proc/diskstats 中的扇区数固定以 512 字节为单位
+const sectorSize = 512
+
+// diskStat /proc/diskstats 中单个设备的累计值，时间的单位为毫秒
+type diskStat struct {
+	name           string
+	reads          uint64
+	sectorsRead    uint64
+	readMs         uint64
+	writes         uint64
+	sectorsWritten uint64
+	writeMs        uint64
+	ioMs           uint64
+	weightedIOMs   uint64
+	timestamp      time.Time
+	wholeDevice    bool
+}
+
+func readDiskStats() ([]diskStat, error) {
+	f, err := os.Open("/proc/diskstats")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stats, err := parseDiskStats(f)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range stats {
+		stats[i].timestamp = now
+		stats[i].wholeDevice = isWholeDevice(stats[i].name)
+	}
+	return stats, nil
+}
+
+// parseDiskStats 解析 /proc/diskstats，字段的含义见内核文档 Documentation/admin-guide/iostats.rst
+func parseDiskStats(r io.Reader) ([]diskStat, error) {
+	var stats []diskStat
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 14 {
+			continue
+		}
+		stat := diskStat{name: fields[2]}
+		for _, f := range []struct {
+			idx int
+			dst *uint64
+		}{
+			{3, &stat.reads}, {5, &stat.sectorsRead}, {6, &stat.readMs},
+			{7, &stat.writes}, {9, &stat.sectorsWritten}, {10, &stat.writeMs},
+			{12, &stat.ioMs}, {13, &stat.weightedIOMs},
+		} {
+			v, err := strconv.ParseUint(fields[f.idx], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse diskstats of %s: %v", stat.name, err)
+			}
+			*f.dst = v
+		}
+		stats = append(stats, stat)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// isWholeDevice 只统计 /sys/block 下的设备（不包括分区），忽略 loop 和 ram 设备
+func isWholeDevice(name string) bool {
+	if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+		return false
+	}
+	_, err := os.Stat("/sys/block/" + strings.ReplaceAll(name, "/", "!"))
+	return err == nil
+}
+
+// diskIO 根据前后两次的累计值计算设备的 I/O 情况，计算方式与 iostat -x 相同
+func diskIO(before, after diskStat) model.DiskIO {
+	elapsedMs := float64(after.timestamp.Sub(before.timestamp).Milliseconds())
+	if elapsedMs <= 0 {
+		elapsedMs = 1000
+	}
+	perSecond := 1000 / elapsedMs
+	reads := counterDelta(after.reads, before.reads)
+	writes := counterDelta(after.writes, before.writes)
+	res := model.DiskIO{
+		Device:     after.name,
+		ReadIOPS:   float64(reads) * perSecond,
+		WriteIOPS:  float64(writes) * perSecond,
+		ReadBytes:  uint64(float64(counterDelta(after.sectorsRead, before.sectorsRead)*sectorSize) * perSecond),
+		WriteBytes: uint64(float64(counterDelta(after.sectorsWritten, before.sectorsWritten)*sectorSize) * perSecond),
+		QueueDepth: float64(counterDelta(after.weightedIOMs, before.weightedIOMs)) / elapsedMs,
+		Util:       float64(counterDelta(after.ioMs, before.ioMs)) / elapsedMs * 100.0,
+	}
+	if reads+writes > 0 {
+		ioMs := counterDelta(after.readMs, before.readMs) + counterDelta(after.writeMs, before.writeMs)
+		res.AwaitMs = float64(ioMs) / float64(reads+writes)
+	}
+	if res.Util > 100 {
+		res.Util = 100
+	}
+	return res
+}
+
+// devicesIO 两次都出现的整块设备的 I/O 情况
+func devicesIO(before, after []diskStat) []model.DiskIO {
+	prev := make(map[string]diskStat, len(before))
+	for _, stat := range before {
+		prev[stat.name] = stat
+	}
+	var res []model.DiskIO
+	for _, stat := range after {
+		if p, ok := prev[stat.name]; ok && stat.wholeDevice {
+			res = append(res, diskIO(p, stat))
+		}
+	}
+	return res
+}
@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"systeminfoagent/diskusage"
+	"systeminfoagent/model"
+)
+
+// 5.5 之后的内核有 20 列（增加了 discard 和 flush），4.18 之前只有 14 列，只使用前 14 列
+const procDiskStats = `   7       0 loop0 52 0 2170 11 0 0 0 0 0 24 11 0 0 0 0
+   8       0 sda 120345 2031 9187234 45123 98765 54321 4567890 123456 0 87654 168579 0 0 0 0 150 32
+   8       1 sda1 120001 2031 9180000 45000 98700 54321 4567000 123400 0 87600 168400 0 0 0 0
+   8       2 sda2 100 200 300 400
+ 253       0 dm-0 1 2 3 4 5 6 7 8 9 10 11
+`
+
+func TestParseDiskStats(t *testing.T) {
+	stats, err := parseDiskStats(strings.NewReader(procDiskStats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(stats))
+	for _, s := range stats {
+		names = append(names, s.name)
+	}
+	// 2.6.25 之前的内核中分区只有 4 个数据列，这样的行被跳过
+	if want := []string{"loop0", "sda", "sda1", "dm-0"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("devices = %v, want %v", names, want)
+	}
+	want := diskStat{
+		name:           "sda",
+		reads:          120345,
+		sectorsRead:    9187234,
+		readMs:         45123,
+		writes:         98765,
+		sectorsWritten: 4567890,
+		writeMs:        123456,
+		ioMs:           87654,
+		weightedIOMs:   168579,
+	}
+	if stats[1] != want {
+		t.Errorf("sda = %+v, want %+v", stats[1], want)
+	}
+	want = diskStat{name: "dm-0", reads: 1, sectorsRead: 3, readMs: 4, writes: 5, sectorsWritten: 7, writeMs: 8, ioMs: 10, weightedIOMs: 11}
+	if stats[3] != want {
+		t.Errorf("dm-0 = %+v, want %+v", stats[3], want)
+	}
+
+	if _, err := parseDiskStats(strings.NewReader("8 0 sda 1 2 3 4 5 6 x 8 9 10 11\n")); err == nil {
+		t.Error("expected an error for a malformed line")
+	}
+}
+
+func TestDiskIO(t *testing.T) {
+	now := time.Now()
+	before := diskStat{name: "sda", reads: 100, sectorsRead: 1000, readMs: 50, writes: 200, sectorsWritten: 4000, writeMs: 150, ioMs: 1000, weightedIOMs: 2000, timestamp: now}
+	after := diskStat{name: "sda", reads: 300, sectorsRead: 5000, readMs: 250, writes: 400, sectorsWritten: 8000, writeMs: 550, ioMs: 2500, weightedIOMs: 5000, timestamp: now.Add(2 * time.Second)}
+	got := diskIO(before, after)
+	want := model.DiskIO{
+		Device:     "sda",
+		ReadIOPS:   100,
+		WriteIOPS:  100,
+		ReadBytes:  4000 * sectorSize / 2,
+		WriteBytes: 4000 * sectorSize / 2,
+		AwaitMs:    (200 + 400) / 400.0,
+		QueueDepth: 1.5,
+		Util:       75,
+	}
+	if got != want {
+		t.Errorf("diskIO() = %+v, want %+v", got, want)
+	}
+
+	// 计数器回绕以及 %util 超过 100 的情况
+	after = diskStat{name: "sda", reads: 50, ioMs: 5000, timestamp: now.Add(time.Second)}
+	got = diskIO(before, after)
+	if got.ReadIOPS != 0 || got.Util != 100 || got.AwaitMs != 0 {
+		t.Errorf("diskIO() after wraparound = %+v", got)
+	}
+}
+
+func TestDevicesIO(t *testing.T) {
+	now := time.Now()
+	before := []diskStat{
+		{name: "sda", reads: 1, timestamp: now, wholeDevice: true},
+		{name: "sda1", reads: 1, timestamp: now},
+	}
+	after := []diskStat{
+		{name: "sda", reads: 11, timestamp: now.Add(time.Second), wholeDevice: true},
+		{name: "sda1", reads: 11, timestamp: now.Add(time.Second)},
+		// 新出现的设备没有之前的数据
+		{name: "sdb", reads: 5, timestamp: now.Add(time.Second), wholeDevice: true},
+	}
+	res := devicesIO(before, after)
+	if len(res) != 1 || res[0].Device != "sda" || res[0].ReadIOPS != 10 {
+		t.Errorf("devicesIO() = %+v", res)
+	}
+}
+
+func TestRootDiskStatName(t *testing.T) {
+	mounts := []diskusage.Mount{
+		{Device: "/dev/nvme0n1p2", Path: "/boot", FsType: "ext4"},
+		{Device: "/dev/nvme0n1p1", Path: "/", FsType: "ext4"},
+		{Device: "server:/export", Path: "/mnt/nfs", FsType: "nfs4"},
+	}
+	if got := diskStatName(mountDevice(mounts, "/")); got != "nvme0n1p1" {
+		t.Errorf("root device = %q, want nvme0n1p1", got)
+	}
+	if got := diskStatName(mountDevice(mounts, "/mnt/nfs")); got != "" {
+		t.Errorf("nfs device = %q, want empty", got)
+	}
+	if got := mountDevice(mounts, "/data"); got != "" {
+		t.Errorf("unmounted path device = %q, want empty", got)
+	}
+
+	// /dev/mapper 下的设备是指向 dm-N 的符号链接
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "dm-0"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "vg-root")
+	if err := os.Symlink("dm-0", link); err != nil {
+		t.Fatal(err)
+	}
+	if got := diskStatName("/dev/../" + link); got != "dm-0" {
+		t.Errorf("mapper device = %q, want dm-0", got)
+	}
+}
@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"systeminfoagent/model"
+
+	"github.com/mackerelio/go-osstat/loadavg"
+)
+
+// LoadCollector 采集 1/5/15 分钟的平均负载、可运行和阻塞的任务数以及在线的 CPU 数
+type LoadCollector struct{}
+
+func (*LoadCollector) Collect(metric *model.NodeMetric) error {
+	avg, err := loadavg.Get()
+	if err != nil {
+		return fmt.Errorf("get loadavg: %v", err)
+	}
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return fmt.Errorf("get procs: %v", err)
+	}
+	defer f.Close()
+	runnable, blocked, err := parseProcs(f)
+	if err != nil {
+		return fmt.Errorf("get procs: %v", err)
+	}
+	metric.Load = model.Load{
+		Valid:    true,
+		Load1:    avg.Loadavg1,
+		Load5:    avg.Loadavg5,
+		Load15:   avg.Loadavg15,
+		Runnable: runnable,
+		Blocked:  blocked,
+		CPUs:     onlineCPUs(),
+	}
+	return nil
+}
+
+// parseProcs 读取 /proc/stat 中的 procs_running 和 procs_blocked
+func parseProcs(r io.Reader) (runnable, blocked uint64, err error) {
+	var okRunning, okBlocked bool
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "procs_running":
+			runnable, err = strconv.ParseUint(fields[1], 10, 64)
+			okRunning = true
+		case "procs_blocked":
+			blocked, err = strconv.ParseUint(fields[1], 10, 64)
+			okBlocked = true
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("parse %s: %v", fields[0], err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	if !okRunning || !okBlocked {
+		return 0, 0, fmt.Errorf("procs_running or procs_blocked not found")
+	}
+	return runnable, blocked, nil
+}
+
+// onlineCPUs 读取 /sys/devices/system/cpu/online（形如 0-3,6），读取失败时使用 runtime.NumCPU
+func onlineCPUs() int {
+	data, err := os.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return runtime.NumCPU()
+	}
+	if n, err := parseCPUList(strings.TrimSpace(string(data))); err == nil && n > 0 {
+		return n
+	}
+	return runtime.NumCPU()
+}
+
+func parseCPUList(list string) (int, error) {
+	n := 0
+	for _, part := range strings.Split(list, ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if idx := strings.IndexByte(part, '-'); idx >= 0 {
+			lo, hi = part[:idx], part[idx+1:]
+		}
+		from, err := strconv.Atoi(lo)
+		if err != nil {
+			return 0, err
+		}
+		to, err := strconv.Atoi(hi)
+		if err != nil || to < from {
+			return 0, fmt.Errorf("invalid cpu range %q", part)
+		}
+		n += to - from + 1
+	}
+	return n, nil
+}
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// netDevStat /proc/net/dev 中单个网卡的累计值
+type netDevStat struct {
+	rxBytes, rxPackets, rxErrors, rxDropped uint64
+	txBytes, txPackets, txErrors, txDropped uint64
+}
+
+func readNetDevStats() (map[string]netDevStat, error) {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseNetDevStats(f)
+}
+
+// parseNetDevStats 解析 /proc/net/dev，前两行为表头
+func parseNetDevStats(r io.Reader) (map[string]netDevStat, error) {
+	res := map[string]netDevStat{}
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		idx := strings.IndexByte(line, ':')
+		if lineNo <= 2 || idx < 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[:idx])
+		fields := strings.Fields(line[idx+1:])
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("parse net dev %s: expected 16 fields, got %d", name, len(fields))
+		}
+		var stat netDevStat
+		for _, f := range []struct {
+			idx int
+			dst *uint64
+		}{
+			{0, &stat.rxBytes}, {1, &stat.rxPackets}, {2, &stat.rxErrors}, {3, &stat.rxDropped},
+			{8, &stat.txBytes}, {9, &stat.txPackets}, {10, &stat.txErrors}, {11, &stat.txDropped},
+		} {
+			v, err := strconv.ParseUint(fields[f.idx], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse net dev %s: %v", name, err)
+			}
+			*f.dst = v
+		}
+		res[name] = stat
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func defaultRouteInterface() (string, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return parseDefaultRoute(f)
+}
+
+// parseDefaultRoute 解析 /proc/net/route，返回目的地址和掩码都为 0 的路由中 metric 最小的网卡
+func parseDefaultRoute(r io.Reader) (string, error) {
+	name, best := "", uint64(0)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(scanner.Text())
+		if lineNo == 1 || len(fields) < 8 {
+			continue
+		}
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		metric, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("parse route %s: %v", fields[0], err)
+		}
+		if name == "" || metric < best {
+			name, best = fields[0], metric
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", fmt.Errorf("no default route")
+	}
+	return name, nil
+}
+
+// linkSpeed 读取 /sys/class/net/<name>/speed（Mb/s），虚拟网卡返回 -1 或者读取失败，此时返回 0
+func linkSpeed(name string) uint64 {
+	data, err := os.ReadFile("/sys/class/net/" + name + "/speed")
+	if err != nil {
+		return 0
+	}
+	speed, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || speed <= 0 {
+		return 0
+	}
+	return uint64(speed)
+}
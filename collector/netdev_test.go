@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+// 前两行为表头，接口名和第一列之间可能没有空格
+const netDevFixture = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  123456     789    0    0    0     0          0         0   123456     789    0    0    0     0       0          0
+  eth0:98765432 123456    1    2    0     0          0        10 87654321  65432    3    4    0     0       0          0
+`
+
+func TestParseNetDevStats(t *testing.T) {
+	stats, err := parseNetDevStats(strings.NewReader(netDevFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("got %d interfaces, want 2: %v", len(stats), stats)
+	}
+	want := netDevStat{
+		rxBytes: 98765432, rxPackets: 123456, rxErrors: 1, rxDropped: 2,
+		txBytes: 87654321, txPackets: 65432, txErrors: 3, txDropped: 4,
+	}
+	if got := stats["eth0"]; got != want {
+		t.Errorf("eth0 = %+v, want %+v", got, want)
+	}
+	if got := stats["lo"]; got.rxBytes != 123456 || got.txPackets != 789 {
+		t.Errorf("lo = %+v", got)
+	}
+}
+
+func TestParseNetDevStatsErrors(t *testing.T) {
+	header := "Inter-|   Receive\n face |bytes\n"
+	for name, input := range map[string]string{
+		"short":   header + "  eth0: 1 2 3 4 5 6 7 8\n",
+		"invalid": header + "  eth0: 1 2 3 4 5 6 7 8 x 10 11 12 13 14 15 16\n",
+	} {
+		if _, err := parseNetDevStats(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseDefaultRoute(t *testing.T) {
+	const header = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"single", header +
+			"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+			"eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n", "eth0"},
+		{"lowest metric", header +
+			"wlan0\t00000000\t0102A8C0\t0003\t0\t0\t600\t00000000\t0\t0\t0\n" +
+			"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n", "eth0"},
+		{"no default", header +
+			"eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseDefaultRoute(strings.NewReader(c.input))
+			if c.want == "" {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"systeminfoagent/model"
+)
+
+// coreStat 单个 CPU 累计的 jiffies
+type coreStat struct {
+	idle, total uint64
+}
+
+func readCoreStats() ([]coreStat, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseCoreStats(f)
+}
+
+// parseCoreStats 读取 /proc/stat 中 cpu0、cpu1 ... 各行，按出现的顺序返回
+// idle 包含 iowait，total 不包含已经计入 user 和 nice 的 guest 和 guest_nice
+func parseCoreStats(r io.Reader) ([]coreStat, error) {
+	var stats []coreStat
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") || fields[0] == "cpu" {
+			continue
+		}
+		var stat coreStat
+		for i, field := range fields[1:] {
+			v, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %v", fields[0], err)
+			}
+			switch i {
+			case 3, 4: // idle, iowait
+				stat.idle += v
+			case 8, 9: // guest, guest_nice
+				continue
+			}
+			stat.total += v
+		}
+		stats = append(stats, stat)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(stats) == 0 {
+		return nil, fmt.Errorf("no per-cpu lines found")
+	}
+	return stats, nil
+}
+
+// summarizeCores 根据前后两次的统计计算每个 CPU 的繁忙百分比，CPU 上下线导致数量不一致时返回错误
+func summarizeCores(before, after []coreStat) (*model.PerCore, error) {
+	if len(before) != len(after) {
+		return nil, fmt.Errorf("cpu count changed from %d to %d", len(before), len(after))
+	}
+	res := &model.PerCore{Busy: make([]float64, len(after))}
+	for i := range after {
+		total := counterDelta(after[i].total, before[i].total)
+		idle := counterDelta(after[i].idle, before[i].idle)
+		if total > 0 && idle <= total {
+			res.Busy[i] = float64(total-idle) / float64(total) * 100.0
+		}
+		res.Mean += res.Busy[i]
+		res.MaxBusy = math.Max(res.MaxBusy, res.Busy[i])
+	}
+	res.Mean /= float64(len(res.Busy))
+	for _, busy := range res.Busy {
+		res.StdDev += (busy - res.Mean) * (busy - res.Mean)
+	}
+	res.StdDev = math.Sqrt(res.StdDev / float64(len(res.Busy)))
+	return res, nil
+}
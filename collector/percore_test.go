@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const procStat = `cpu  2000 100 1000 40000 500 50 50 300 200 0
+cpu0 1000 50 500 20000 250 25 25 150 100 0
+cpu1 1000 50 500 20000 250 25 25 150 100 0
+intr 123456 0 0
+ctxt 987654
+btime 1700000000
+processes 4242
+procs_running 2
+procs_blocked 0
+softirq 1000 0 0
+`
+
+func TestParseCoreStats(t *testing.T) {
+	stats, err := parseCoreStats(strings.NewReader(procStat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// idle 包含 iowait，total 不包含 guest 和 guest_nice
+	want := []coreStat{
+		{idle: 20250, total: 1000 + 50 + 500 + 20000 + 250 + 25 + 25 + 150},
+		{idle: 20250, total: 1000 + 50 + 500 + 20000 + 250 + 25 + 25 + 150},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("parseCoreStats() = %+v, want %+v", stats, want)
+	}
+
+	// 旧内核没有 steal、guest 等列
+	stats, err = parseCoreStats(strings.NewReader("cpu 10 0 10 80\ncpu0 10 0 10 80\n"))
+	if err != nil || !reflect.DeepEqual(stats, []coreStat{{idle: 80, total: 100}}) {
+		t.Errorf("short lines: %+v %v", stats, err)
+	}
+
+	if _, err := parseCoreStats(strings.NewReader("cpu 1 2 3 4 5\nintr 1\n")); err == nil {
+		t.Error("expected an error without per-cpu lines")
+	}
+	if _, err := parseCoreStats(strings.NewReader("cpu0 1 2 x 4 5\n")); err == nil {
+		t.Error("expected an error for a malformed line")
+	}
+}
+
+func TestSummarizeCores(t *testing.T) {
+	before := []coreStat{{idle: 100, total: 200}, {idle: 100, total: 200}, {idle: 100, total: 200}}
+	after := []coreStat{
+		{idle: 200, total: 300}, // 0% busy
+		{idle: 150, total: 300}, // 50% busy
+		{idle: 100, total: 300}, // 100% busy
+	}
+	res, err := summarizeCores(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Busy, []float64{0, 50, 100}) || res.Mean != 50 || res.MaxBusy != 100 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if want := math.Sqrt(5000.0 / 3); math.Abs(res.StdDev-want) > 1e-9 {
+		t.Errorf("StdDev = %v, want %v", res.StdDev, want)
+	}
+
+	// 没有变化的 CPU 视为空闲
+	res, err = summarizeCores(before[:1], before[:1])
+	if err != nil || res.Busy[0] != 0 {
+		t.Errorf("idle core: %+v %v", res, err)
+	}
+
+	// CPU 上下线
+	if _, err := summarizeCores(before, after[:2]); err == nil || !strings.Contains(err.Error(), "cpu count changed") {
+		t.Errorf("summarizeCores() = %v, want cpu count changed", err)
+	}
+}
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"systeminfoagent/model"
+)
+
+// PressureCollector 采集 /proc/pressure 下 cpu、memory 和 io 的 PSI 数据
+// 内核不支持 PSI（4.20 之前或者没有开启 CONFIG_PSI）时只将 Pressure 标记为无效，不返回错误
+type PressureCollector struct {
+	// Dir 为空时使用 /proc/pressure
+	Dir string
+}
+
+func (pc *PressureCollector) Collect(metric *model.NodeMetric) error {
+	dir := pc.Dir
+	if dir == "" {
+		dir = "/proc/pressure"
+	}
+	pressure := model.Pressure{Valid: true}
+	for _, resource := range []struct {
+		name string
+		dst  *model.ResourcePressure
+	}{{"cpu", &pressure.CPU}, {"memory", &pressure.Memory}, {"io", &pressure.IO}} {
+		res, err := readPressure(filepath.Join(dir, resource.name))
+		// 以 psi=0 启动的内核中文件存在，但是读取时返回 EOPNOTSUPP
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.EOPNOTSUPP) {
+			metric.Pressure = model.Pressure{}
+			return nil
+		}
+		if err != nil {
+			metric.Pressure = model.Pressure{}
+			return fmt.Errorf("get pressure: %v", err)
+		}
+		*resource.dst = res
+	}
+	metric.Pressure = pressure
+	return nil
+}
+
+func readPressure(path string) (model.ResourcePressure, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return model.ResourcePressure{}, err
+	}
+	defer f.Close()
+	res, err := parsePressure(f)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", path, err)
+	}
+	return res, nil
+}
+
+// parsePressure 解析 /proc/pressure 下的一个文件：
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//
+// 旧内核的 cpu 文件只有 some 一行，此时 Full 为零值
+func parsePressure(r io.Reader) (model.ResourcePressure, error) {
+	var res model.ResourcePressure
+	var okSome bool
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var stat *model.PressureStat
+		switch fields[0] {
+		case "some":
+			stat, okSome = &res.Some, true
+		case "full":
+			stat = &res.Full
+		default:
+			return res, fmt.Errorf("unknown line %q", fields[0])
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return res, fmt.Errorf("invalid field %q", field)
+			}
+			var err error
+			switch kv[0] {
+			case "avg10":
+				stat.Avg10, err = strconv.ParseFloat(kv[1], 64)
+			case "avg60":
+				stat.Avg60, err = strconv.ParseFloat(kv[1], 64)
+			case "avg300":
+				stat.Avg300, err = strconv.ParseFloat(kv[1], 64)
+			case "total":
+				stat.Total, err = strconv.ParseUint(kv[1], 10, 64)
+			}
+			if err != nil {
+				return res, fmt.Errorf("parse %s %s: %v", fields[0], kv[0], err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return res, fmt.Errorf("read pressure: %w", err)
+	}
+	if !okSome {
+		return res, fmt.Errorf("missing some line")
+	}
+	return res, nil
+}
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"systeminfoagent/model"
+)
+
+func TestPressureCollector(t *testing.T) {
+	memory := model.ResourcePressure{
+		Some: model.PressureStat{Avg10: 12.5, Avg60: 6.1, Avg300: 2, Total: 9876543},
+		Full: model.PressureStat{Avg10: 8.25, Avg60: 4, Avg300: 1.1, Total: 5432100},
+	}
+	io := model.ResourcePressure{
+		Some: model.PressureStat{Avg10: 30.4, Avg60: 20.15, Avg300: 10.05, Total: 77777777},
+		Full: model.PressureStat{Avg10: 25, Avg60: 18, Avg300: 9, Total: 66666666},
+	}
+	tests := []struct {
+		dir     string
+		want    model.Pressure
+		wantErr bool
+	}{
+		{
+			dir: "full",
+			want: model.Pressure{Valid: true, Memory: memory, IO: io, CPU: model.ResourcePressure{
+				Some: model.PressureStat{Avg10: 1.52, Avg60: 0.87, Avg300: 0.25, Total: 123456},
+			}},
+		},
+		{
+			// 5.13 之前的内核 cpu 文件没有 full 一行
+			dir: "nofull",
+			want: model.Pressure{Valid: true, Memory: memory, IO: io, CPU: model.ResourcePressure{
+				Some: model.PressureStat{Avg10: 3.1, Avg60: 2.2, Avg300: 1.3, Total: 424242},
+			}},
+		},
+		{
+			// 内核不支持 PSI 时没有 /proc/pressure
+			dir:  "missing",
+			want: model.Pressure{},
+		},
+		{
+			dir:     "malformed",
+			want:    model.Pressure{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		metric := &model.NodeMetric{Pressure: model.Pressure{Valid: true}}
+		err := (&PressureCollector{Dir: filepath.Join("testdata", "pressure", tt.dir)}).Collect(metric)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Collect() = %v, want error %v", tt.dir, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(metric.Pressure, tt.want) {
+			t.Errorf("%s: Pressure = %+v, want %+v", tt.dir, metric.Pressure, tt.want)
+		}
+	}
+}
+
+func TestParsePressureErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"", "missing some line"},
+		{"full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n", "missing some line"},
+		{"some avg10=0.00 avg60=0.00 avg300=0.00 total=0\npartial avg10=0.00\n", `unknown line "partial"`},
+		{"some avg10 avg60=0.00 avg300=0.00 total=0\n", `invalid field "avg10"`},
+		{"some avg10=abc avg60=0.00 avg300=0.00 total=0\n", "parse some avg10"},
+		{"some avg10=0.00 avg60=0.00 avg300=0.00 total=-1\n", "parse some total"},
+	}
+	for _, tt := range tests {
+		_, err := parsePressure(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parsePressure(%q) = %v, want error containing %q", tt.input, err, tt.err)
+		}
+	}
+}
+
+func TestParsePressureUnknownFields(t *testing.T) {
+	// 新内核可能增加字段，不认识的字段忽略，空行跳过
+	res, err := parsePressure(strings.NewReader("\nsome avg10=1.00 avg60=2.00 avg300=3.00 avg900=4.00 total=5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := model.ResourcePressure{Some: model.PressureStat{Avg10: 1, Avg60: 2, Avg300: 3, Total: 5}}
+	if res != want {
+		t.Errorf("parsePressure() = %+v, want %+v", res, want)
+	}
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"systeminfoagent/model"
+)
+
+// tcpStates include/net/tcp_states.h 中的状态，/proc/net/tcp 中以十六进制表示
+var tcpStates = map[uint64]string{
+	0x01: "ESTABLISHED",
+	0x02: "SYN_SENT",
+	0x03: "SYN_RECV",
+	0x04: "FIN_WAIT1",
+	0x05: "FIN_WAIT2",
+	0x06: "TIME_WAIT",
+	0x07: "CLOSE",
+	0x08: "CLOSE_WAIT",
+	0x09: "LAST_ACK",
+	0x0a: "LISTEN",
+	0x0b: "CLOSING",
+	0x0c: "NEW_SYN_RECV",
+}
+
+// SocketCollector 采集各个状态的 TCP socket 数量、conntrack 表的使用情况以及临时端口的使用情况
+type SocketCollector struct{}
+
+func (*SocketCollector) Collect(metric *model.NodeMetric) error {
+	portLow, portHigh, err := readPortRange("/proc/sys/net/ipv4/ip_local_port_range")
+	if err != nil {
+		return fmt.Errorf("get sockets: %v", err)
+	}
+	sockets := model.Sockets{
+		Valid:               true,
+		TCPStates:           map[string]uint64{},
+		EphemeralPortsTotal: portHigh - portLow + 1,
+	}
+	ports := map[uint64]bool{}
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// 关闭了 IPv6 时没有 tcp6
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("get sockets: %v", err)
+		}
+		err = parseTCPSockets(f, func(state string, localPort uint64) {
+			sockets.TCPStates[state]++
+			if state != "LISTEN" && localPort >= portLow && localPort <= portHigh {
+				ports[localPort] = true
+			}
+		})
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("get sockets: %s: %v", path, err)
+		}
+	}
+	sockets.EphemeralPortsUsed = uint64(len(ports))
+	// 以下文件不存在时（没有加载 nf_conntrack，或者在容器中）上限保持为 0
+	sockets.TimeWaitMax, _ = readUint("/proc/sys/net/ipv4/tcp_max_tw_buckets")
+	if sockets.ConntrackMax, err = readUint("/proc/sys/net/netfilter/nf_conntrack_max"); err == nil {
+		sockets.ConntrackCount, _ = readUint("/proc/sys/net/netfilter/nf_conntrack_count")
+	}
+	metric.Sockets = sockets
+	return nil
+}
+
+// parseTCPSockets 解析 /proc/net/tcp 或 /proc/net/tcp6，对每个 socket 调用 fn
+func parseTCPSockets(r io.Reader, fn func(state string, localPort uint64)) error {
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+		if lineNo == 1 || len(fields) < 4 {
+			continue
+		}
+		idx := strings.LastIndexByte(fields[1], ':')
+		if idx < 0 {
+			return fmt.Errorf("line %d: invalid local address %q", lineNo, fields[1])
+		}
+		port, err := strconv.ParseUint(fields[1][idx+1:], 16, 16)
+		if err != nil {
+			return fmt.Errorf("line %d: parse local port: %v", lineNo, err)
+		}
+		st, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			return fmt.Errorf("line %d: parse state: %v", lineNo, err)
+		}
+		state, ok := tcpStates[st]
+		if !ok {
+			state = fmt.Sprintf("UNKNOWN_%02X", st)
+		}
+		fn(state, port)
+	}
+	return scanner.Err()
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readPortRange 读取 ip_local_port_range，格式为 "32768\t60999"
+func readPortRange(path string) (uint64, uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("invalid port range %q", strings.TrimSpace(string(data)))
+	}
+	low, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range: %v", err)
+	}
+	high, err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil || high < low {
+		return 0, 0, fmt.Errorf("invalid port range %q", strings.TrimSpace(string(data)))
+	}
+	return low, high, nil
+}
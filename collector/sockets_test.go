@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const tcpFixture = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 21345 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:9C40 01 00000000:00000000 00:00000000 00000000  1000        0 31245 1 0000000000000000 20 4 30 10 -1
+   2: 0A00020F:9C41 5DB8D822:01BB 06 00000000:00000000 03:00001234 00000000     0        0 0 3 0000000000000000
+`
+
+// tcp6 的地址为 32 个十六进制字符，端口同样在最后一个冒号之后
+const tcp6Fixture = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0050 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 22345 1 0000000000000000 100 0 0 10 0
+   1: 0000000000000000FFFF00000100007F:9C42 0000000000000000FFFF00000100007F:0050 01 00000000:00000000 00:00000000 00000000    33        0 32245 1 0000000000000000 20 4 30 10 -1
+   2: 00000000000000000000000001000000:D431 00000000000000000000000001000000:0050 0D 00000000:00000000 00:00000000 00000000     0        0 0 1 0000000000000000
+`
+
+type tcpSocket struct {
+	state string
+	port  uint64
+}
+
+func collectSockets(t *testing.T, input string) []tcpSocket {
+	t.Helper()
+	var res []tcpSocket
+	err := parseTCPSockets(strings.NewReader(input), func(state string, localPort uint64) {
+		res = append(res, tcpSocket{state, localPort})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestParseTCPSockets(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  []tcpSocket
+	}{
+		{"tcp", tcpFixture, []tcpSocket{{"LISTEN", 22}, {"ESTABLISHED", 8080}, {"TIME_WAIT", 40001}}},
+		{"tcp6", tcp6Fixture, []tcpSocket{{"LISTEN", 80}, {"ESTABLISHED", 40002}, {"UNKNOWN_0D", 54321}}},
+		{"header only", "  sl  local_address rem_address   st\n", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := collectSockets(t, c.input); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseTCPSocketsErrors(t *testing.T) {
+	header := "  sl  local_address rem_address   st\n"
+	for name, line := range map[string]string{
+		"no port":   "   0: 00000000 00000000:0000 0A\n",
+		"bad port":  "   0: 00000000:XYZ0 00000000:0000 0A\n",
+		"bad state": "   0: 00000000:0016 00000000:0000 ZZ\n",
+	} {
+		err := parseTCPSockets(strings.NewReader(header+line), func(string, uint64) {})
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestReadPortRange(t *testing.T) {
+	cases := []struct {
+		name      string
+		content   string
+		low, high uint64
+		wantErr   bool
+	}{
+		{"default", "32768\t60999\n", 32768, 60999, false},
+		{"spaces", "1024 65535", 1024, 65535, false},
+		{"single port", "40000\t40000\n", 40000, 40000, false},
+		{"reversed", "60999\t32768\n", 0, 0, true},
+		{"one field", "32768\n", 0, 0, true},
+		{"out of range", "32768\t70000\n", 0, 0, true},
+		{"not a number", "low\thigh\n", 0, 0, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ip_local_port_range")
+			if err := os.WriteFile(path, []byte(c.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			low, high, err := readPortRange(path)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %d-%d", low, high)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if low != c.low || high != c.high {
+				t.Errorf("got %d-%d, want %d-%d", low, high, c.low, c.high)
+			}
+		})
+	}
+	if _, _, err := readPortRange(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing file: expected error")
+	}
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// swapCounters /proc/vmstat 中累计换入换出的页数
+type swapCounters struct {
+	in, out uint64
+}
+
+func readSwapCounters() (swapCounters, error) {
+	f, err := os.Open("/proc/vmstat")
+	if err != nil {
+		return swapCounters{}, err
+	}
+	defer f.Close()
+	return parseSwapCounters(f)
+}
+
+// parseSwapCounters 读取 /proc/vmstat 中的 pswpin 和 pswpout
+func parseSwapCounters(r io.Reader) (swapCounters, error) {
+	var c swapCounters
+	var okIn, okOut bool
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		var err error
+		switch fields[0] {
+		case "pswpin":
+			c.in, err = strconv.ParseUint(fields[1], 10, 64)
+			okIn = true
+		case "pswpout":
+			c.out, err = strconv.ParseUint(fields[1], 10, 64)
+			okOut = true
+		}
+		if err != nil {
+			return c, fmt.Errorf("parse %s: %v", fields[0], err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return c, err
+	}
+	if !okIn || !okOut {
+		return c, fmt.Errorf("pswpin or pswpout not found")
+	}
+	return c, nil
+}
@@ -70,7 +70,11 @@ func diskinfo() {
 			afterR, afterW = di.ReadsCompleted, di.WritesCompleted
 		}
 	}
-	usage := diskusage.NewDiskUsage("/")
+	usage, err := diskusage.NewDiskUsage("/")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
 	var KB = uint64(1024)
 
 	fmt.Printf("read times in 1s: %d\n", afterR-beforeR)
@@ -80,6 +84,7 @@ func diskinfo() {
 	fmt.Println("Size:", usage.Size()/(KB*KB))
 	fmt.Println("Used:", usage.Used()/(KB*KB))
 	fmt.Println("Usage:", usage.Usage()*100, "%")
+	fmt.Println("Type:", usage.TypeName(), "ReadOnly:", usage.ReadOnly())
 }
 
 func netinfo() {
@@ -1,22 +1,29 @@
 package diskusage
 
-import "syscall"
+import (
+	"fmt"
+	"syscall"
+)
+
+// stRdonly is ST_RDONLY in statfs(2) f_flags
+const stRdonly = 0x1
 
 // DiskUsage contains usage data and provides user-friendly access methods
 type DiskUsage struct {
 	stat *syscall.Statfs_t
 }
 
-// NewDiskUsages returns an object holding the disk usage of volumePath
-// or nil in case of error (invalid path, etc)
-func NewDiskUsage(volumePath string) *DiskUsage {
-
+// NewDiskUsage returns an object holding the disk usage of volumePath
+// or an error in case the path can not be stat'ed (invalid path, etc)
+func NewDiskUsage(volumePath string) (*DiskUsage, error) {
 	var stat syscall.Statfs_t
-	_ = syscall.Statfs(volumePath, &stat)
-	return &DiskUsage{&stat}
+	if err := syscall.Statfs(volumePath, &stat); err != nil {
+		return nil, fmt.Errorf("statfs %s: %v", volumePath, err)
+	}
+	return &DiskUsage{&stat}, nil
 }
 
-// Free returns total free bytes on file system
+// Free returns total free bytes on file system, including blocks reserved for root
 func (du *DiskUsage) Free() uint64 {
 	return du.stat.Bfree * uint64(du.stat.Bsize)
 }
@@ -26,6 +33,14 @@ func (du *DiskUsage) Available() uint64 {
 	return du.stat.Bavail * uint64(du.stat.Bsize)
 }
 
+// Reserved returns free bytes reserved for root, which are not available to unprivileged users
+func (du *DiskUsage) Reserved() uint64 {
+	if du.stat.Bfree < du.stat.Bavail {
+		return 0
+	}
+	return (du.stat.Bfree - du.stat.Bavail) * uint64(du.stat.Bsize)
+}
+
 // Size returns total size of the file system
 func (du *DiskUsage) Size() uint64 {
 	return uint64(du.stat.Blocks) * uint64(du.stat.Bsize)
@@ -36,7 +51,71 @@ func (du *DiskUsage) Used() uint64 {
 	return du.Size() - du.Free()
 }
 
-// Usage returns percentage of use on the file system
+// Usage returns percentage of use on the file system, 0 for file systems without blocks
 func (du *DiskUsage) Usage() float32 {
+	if du.Size() == 0 {
+		return 0
+	}
 	return float32(du.Used()) / float32(du.Size())
 }
+
+// Inodes returns total inodes of the file system, 0 if the file system does not report inodes
+func (du *DiskUsage) Inodes() uint64 {
+	return du.stat.Files
+}
+
+// FreeInodes returns free inodes on file system
+func (du *DiskUsage) FreeInodes() uint64 {
+	return du.stat.Ffree
+}
+
+// UsedInodes returns used inodes on file system
+func (du *DiskUsage) UsedInodes() uint64 {
+	return du.Inodes() - du.FreeInodes()
+}
+
+// InodeUsage returns percentage of inodes in use on the file system, 0 if inodes are not reported
+func (du *DiskUsage) InodeUsage() float32 {
+	if du.Inodes() == 0 {
+		return 0
+	}
+	return float32(du.UsedInodes()) / float32(du.Inodes())
+}
+
+// Type returns the magic number of the file system type, see statfs(2)
+func (du *DiskUsage) Type() int64 {
+	return int64(du.stat.Type)
+}
+
+// TypeName returns the name of the file system type, or the magic number in hex if unknown
+func (du *DiskUsage) TypeName() string {
+	if name, ok := fsTypes[du.Type()]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", du.Type())
+}
+
+// ReadOnly returns whether the file system is mounted read-only
+func (du *DiskUsage) ReadOnly() bool {
+	return du.stat.Flags&stRdonly != 0
+}
+
+// fsTypes magic numbers of common file systems, from linux/magic.h
+var fsTypes = map[int64]string{
+	0xef53:     "ext4", // ext2, ext3 and ext4 share the magic number
+	0x58465342: "xfs",
+	0x9123683e: "btrfs",
+	0x2fc12fc1: "zfs",
+	0x01021994: "tmpfs",
+	0x794c7630: "overlay",
+	0x6969:     "nfs",
+	0xff534d42: "cifs",
+	0x65735546: "fuse",
+	0x4d44:     "vfat",
+	0x5346544e: "ntfs",
+	0xf2f52010: "f2fs",
+	0x3153464a: "jfs",
+	0x52654973: "reiserfs",
+	0x9660:     "iso9660",
+	0x73717368: "squashfs",
+}
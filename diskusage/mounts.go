@@ -0,0 +1,123 @@
+package diskusage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Mount is an entry of the mount table
+type Mount struct {
+	Device   string
+	Path     string
+	FsType   string
+	Options  []string
+	ReadOnly bool
+}
+
+// pseudoFsTypes are file systems which do not store data on a device
+var pseudoFsTypes = map[string]bool{
+	"autofs": true, "bpf": true, "binfmt_misc": true, "cgroup": true, "cgroup2": true,
+	"configfs": true, "debugfs": true, "devpts": true, "devtmpfs": true, "efivarfs": true,
+	"fusectl": true, "hugetlbfs": true, "mqueue": true, "nsfs": true, "overlay": true,
+	"proc": true, "pstore": true, "ramfs": true, "rpc_pipefs": true, "securityfs": true,
+	"selinuxfs": true, "squashfs": true, "sysfs": true, "tmpfs": true, "tracefs": true,
+	"fuse.lxcfs": true, "fuse.gvfsd-fuse": true, "shm": true,
+}
+
+// networkFsTypes are file systems backed by a remote server. statfs on them can block
+// for as long as the server does not respond, forever on a hard NFS mount
+var networkFsTypes = map[string]bool{
+	"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "smbfs": true, "ceph": true,
+	"glusterfs": true, "fuse.glusterfs": true, "fuse.sshfs": true, "fuse.s3fs": true,
+	"9p": true, "lustre": true, "afs": true, "davfs": true,
+}
+
+// Network reports whether the file system is served over the network
+func (m Mount) Network() bool {
+	return networkFsTypes[m.FsType]
+}
+
+// Mounts returns the file systems storing data on this host, read from /proc/self/mounts.
+// See FilterMounts for which entries are returned
+func Mounts() ([]Mount, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, fmt.Errorf("read mount table: %v", err)
+	}
+	defer f.Close()
+	all, err := ParseMounts(f)
+	if err != nil {
+		return nil, err
+	}
+	return FilterMounts(all), nil
+}
+
+// FilterMounts skips pseudo file systems by type. The device name is not checked, so
+// NFS (host:/export) and ZFS (pool/dataset) mounts are kept. A device mounted at several
+// paths (bind mounts) is only returned once, at the first path in the mount table
+func FilterMounts(all []Mount) []Mount {
+	var res []Mount
+	seen := map[string]bool{}
+	for _, m := range all {
+		if pseudoFsTypes[m.FsType] || seen[m.Device] {
+			continue
+		}
+		seen[m.Device] = true
+		res = append(res, m)
+	}
+	return res
+}
+
+// ParseMounts parses a mount table in the format of /proc/mounts, see fstab(5)
+func ParseMounts(r io.Reader) ([]Mount, error) {
+	var res []Mount
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("mount table line %d: expected at least 4 fields", lineNo)
+		}
+		m := Mount{
+			Device:  unescape(fields[0]),
+			Path:    unescape(fields[1]),
+			FsType:  fields[2],
+			Options: strings.Split(fields[3], ","),
+		}
+		for _, opt := range m.Options {
+			if opt == "ro" {
+				m.ReadOnly = true
+			}
+		}
+		res = append(res, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read mount table: %v", err)
+	}
+	return res, nil
+}
+
+// unescape decodes the octal escapes (\040 for space, etc) used in the mount table
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package diskusage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const procMounts = `sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+/dev/sda1 / ext4 rw,relatime,errors=remount-ro 0 0
+/dev/sda1 /var/lib/kubelet/pods/x/volumes ext4 rw,relatime 0 0
+tmpfs /run tmpfs rw,nosuid,nodev,size=812008k,mode=755 0 0
+/dev/sdb1 /mnt/data\040disk xfs ro,relatime,attr2 0 0
+nfs.example.com:/export /mnt/nfs nfs4 rw,relatime,vers=4.2,hard 0 0
+tank/data /tank/data zfs rw,xattr,noacl 0 0
+//srv/share /mnt/share cifs rw,relatime,vers=3.0 0 0
+overlay /var/lib/docker/overlay2/x/merged overlay rw,relatime 0 0
+
+`
+
+func TestParseMounts(t *testing.T) {
+	mounts, err := ParseMounts(strings.NewReader(procMounts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 10 {
+		t.Fatalf("got %d mounts, want 10", len(mounts))
+	}
+	want := Mount{
+		Device:   "/dev/sdb1",
+		Path:     "/mnt/data disk",
+		FsType:   "xfs",
+		Options:  []string{"ro", "relatime", "attr2"},
+		ReadOnly: true,
+	}
+	if !reflect.DeepEqual(mounts[5], want) {
+		t.Errorf("mounts[5] = %+v, want %+v", mounts[5], want)
+	}
+	if mounts[2].ReadOnly {
+		t.Errorf("errors=remount-ro should not be read only: %+v", mounts[2])
+	}
+
+	if _, err := ParseMounts(strings.NewReader("/dev/sda1 / ext4\n")); err == nil {
+		t.Error("expected an error for a short line")
+	}
+}
+
+func TestFilterMounts(t *testing.T) {
+	all, err := ParseMounts(strings.NewReader(procMounts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	var network []string
+	for _, m := range FilterMounts(all) {
+		paths = append(paths, m.Path)
+		if m.Network() {
+			network = append(network, m.Path)
+		}
+	}
+	want := []string{"/", "/mnt/data disk", "/mnt/nfs", "/tank/data", "/mnt/share"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %q, want %q", paths, want)
+	}
+	if want := []string{"/mnt/nfs", "/mnt/share"}; !reflect.DeepEqual(network, want) {
+		t.Errorf("network mounts = %q, want %q", network, want)
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := map[string]string{
+		"/mnt/plain":          "/mnt/plain",
+		`/mnt/with\040space`:  "/mnt/with space",
+		`/mnt/tab\011here`:    "/mnt/tab\there",
+		`/mnt/back\134slash`:  `/mnt/back\slash`,
+		`/mnt/new\012line`:    "/mnt/new\nline",
+		`/mnt/not\escape`:     `/mnt/not\escape`,
+		`/mnt/short\04`:       `/mnt/short\04`,
+		`/mnt/trailing\`:      `/mnt/trailing\`,
+		`/mnt/overflow\777x`:  `/mnt/overflow\777x`,
+		`\040leading\040both`: " leading both",
+	}
+	for in, want := range tests {
+		if got := unescape(in); got != want {
+			t.Errorf("unescape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -5,6 +5,9 @@ go 1.17
 require (
 	github.com/gin-gonic/gin v1.7.7
 	github.com/mackerelio/go-osstat v0.2.1
+	gopkg.in/yaml.v2 v2.2.8
+	k8s.io/api v0.20.0
+	k8s.io/apimachinery v0.20.0
 	k8s.io/kube-scheduler v0.20.0
 )
 
@@ -29,9 +32,6 @@ require (
 	golang.org/x/text v0.3.4 // indirect
 	google.golang.org/protobuf v1.25.0 // indirect
 	gopkg.in/inf.v0 v0.9.1 // indirect
-	gopkg.in/yaml.v2 v2.2.8 // indirect
-	k8s.io/api v0.20.0 // indirect
-	k8s.io/apimachinery v0.20.0 // indirect
 	k8s.io/klog/v2 v2.4.0 // indirect
 	sigs.k8s.io/structured-merge-diff/v4 v4.0.2 // indirect
 )
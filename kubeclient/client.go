@@ -0,0 +1,208 @@
+package kubeclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// Binder 将 pod 绑定到节点上
+type Binder interface {
+	Bind(ctx context.Context, binding *v1.Binding) error
+}
+
+// PodLister 列出节点上的 pod
+type PodLister interface {
+	ListNodePods(ctx context.Context, node string) ([]v1.Pod, error)
+}
+
+// NodePatcher 修改 Node 的 annotation 和 condition
+type NodePatcher interface {
+	PatchNodeAnnotations(ctx context.Context, name string, annotations map[string]string) error
+	SetNodeCondition(ctx context.Context, name string, condition v1.NodeCondition) error
+}
+
+// Config 访问 apiserver 所需的配置，Server 为空时使用 in-cluster 配置
+type Config struct {
+	Server    string `json:"server"`
+	TokenFile string `json:"token_file"`
+	CAFile    string `json:"ca_file"`
+	Insecure  bool   `json:"insecure"`
+}
+
+// RESTClient 直接调用 apiserver REST 接口的简单实现，只包含 master 需要用到的接口
+type RESTClient struct {
+	server    string
+	tokenFile string
+	client    *http.Client
+}
+
+// NewRESTClient 根据配置创建 client
+func NewRESTClient(config Config) (*RESTClient, error) {
+	if config.Server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("kubernetes server not set and not running in cluster")
+		}
+		config.Server = "https://" + net.JoinHostPort(host, port)
+		if config.TokenFile == "" {
+			config.TokenFile = serviceAccountTokenFile
+		}
+		if config.CAFile == "" {
+			config.CAFile = serviceAccountCAFile
+		}
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.Insecure}
+	if config.CAFile != "" {
+		data, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("read ca file: no certificate found in %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &RESTClient{
+		server:    strings.TrimSuffix(config.Server, "/"),
+		tokenFile: config.TokenFile,
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+	}, nil
+}
+
+// listNodesPageSize 分页 list 时每页的 Node 数量，与 client-go 的 pager 相同
+const listNodesPageSize = 500
+
+// ListNodes GET /api/v1/nodes，按 limit 和 continue 分页，避免大集群中一次返回所有 Node
+// continue 过期时（410 Gone）返回错误，由调用者在下一轮重新 list
+func (c *RESTClient) ListNodes(ctx context.Context) ([]v1.Node, error) {
+	var nodes []v1.Node
+	query := url.Values{"limit": {strconv.Itoa(listNodesPageSize)}}
+	for {
+		var list v1.NodeList
+		if err := c.do(ctx, http.MethodGet, "/api/v1/nodes?"+query.Encode(), "", nil, &list); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, list.Items...)
+		if list.Continue == "" {
+			return nodes, nil
+		}
+		query.Set("continue", list.Continue)
+	}
+}
+
+// ListNodePods GET /api/v1/pods?fieldSelector=spec.nodeName={node}
+func (c *RESTClient) ListNodePods(ctx context.Context, node string) ([]v1.Pod, error) {
+	var list v1.PodList
+	path := "/api/v1/pods?fieldSelector=" + url.QueryEscape("spec.nodeName="+node)
+	if err := c.do(ctx, http.MethodGet, path, "", nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// PatchNodeAnnotations PATCH /api/v1/nodes/{name}，只修改给出的 annotation
+func (c *RESTClient) PatchNodeAnnotations(ctx context.Context, name string, annotations map[string]string) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	}
+	return c.do(ctx, http.MethodPatch, "/api/v1/nodes/"+name, "application/merge-patch+json", patch, nil)
+}
+
+// SetNodeCondition PATCH /api/v1/nodes/{name}/status
+// strategic merge patch 按照 type 合并 conditions，不会影响 kubelet 维护的其他 condition
+func (c *RESTClient) SetNodeCondition(ctx context.Context, name string, condition v1.NodeCondition) error {
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{"conditions": []v1.NodeCondition{condition}},
+	}
+	return c.do(ctx, http.MethodPatch, "/api/v1/nodes/"+name+"/status", "application/strategic-merge-patch+json", patch, nil)
+}
+
+// Bind POST /api/v1/namespaces/{namespace}/pods/{name}/binding
+func (c *RESTClient) Bind(ctx context.Context, binding *v1.Binding) error {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/binding", binding.Namespace, binding.Name)
+	return c.do(ctx, http.MethodPost, path, "application/json", binding, nil)
+}
+
+func (c *RESTClient) do(ctx context.Context, method, path, contentType string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, reader)
+	if err != nil {
+		return fmt.Errorf("gen http request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.tokenFile != "" {
+		// token 可能会被轮换，每次请求都重新读取
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return fmt.Errorf("read token: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s: read body: %v", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &StatusError{Code: resp.StatusCode, Message: string(data)}
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("%s %s: unmarshal response: %v", method, path, err)
+		}
+	}
+	return nil
+}
+
+// StatusError apiserver 返回的非 2xx 响应
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("apiserver returned %d: %s", e.Code, e.Message)
+}
+
+// IsNotFound 判断是否为 404 错误
+func IsNotFound(err error) bool {
+	se, ok := err.(*StatusError)
+	return ok && se.Code == http.StatusNotFound
+}
@@ -0,0 +1,42 @@
+package kubeclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestListNodesPaginated(t *testing.T) {
+	pages := map[string]v1.NodeList{
+		"":      {ListMeta: metav1.ListMeta{Continue: "page2"}, Items: []v1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}},
+		"page2": {Items: []v1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}}}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("limit") != "500" {
+			t.Errorf("limit = %q, want 500", r.URL.Query().Get("limit"))
+		}
+		page, ok := pages[r.URL.Query().Get("continue")]
+		if !ok {
+			http.Error(w, "expired", http.StatusGone)
+			return
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+	client, err := NewRESTClient(Config{Server: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := client.ListNodes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || nodes[0].Name != "node-1" || nodes[1].Name != "node-2" {
+		t.Errorf("nodes = %v", nodes)
+	}
+}
@@ -0,0 +1,118 @@
+package kubeclient
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// FakeClient 用于测试的 client，所有操作都保存在内存中
+type FakeClient struct {
+	lock sync.Mutex
+	// BindError 不为 nil 时 Bind 返回该错误
+	BindError error
+	Bindings  []v1.Binding
+	// Nodes ListNodes 返回的节点，通过 AddNode、DeleteNode 修改
+	Nodes map[string]v1.Node
+	// Pods ListNodePods 返回的 pod，按 Spec.NodeName 过滤
+	Pods []v1.Pod
+}
+
+// NewFakeClient 创建一个空的 FakeClient
+func NewFakeClient() *FakeClient {
+	return &FakeClient{Nodes: map[string]v1.Node{}}
+}
+
+func (f *FakeClient) Bind(_ context.Context, binding *v1.Binding) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.BindError != nil {
+		return f.BindError
+	}
+	f.Bindings = append(f.Bindings, *binding)
+	return nil
+}
+
+func (f *FakeClient) ListNodes(_ context.Context) ([]v1.Node, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	res := make([]v1.Node, 0, len(f.Nodes))
+	for _, node := range f.Nodes {
+		res = append(res, *node.DeepCopy())
+	}
+	return res, nil
+}
+
+func (f *FakeClient) ListNodePods(_ context.Context, node string) ([]v1.Pod, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var res []v1.Pod
+	for _, pod := range f.Pods {
+		if pod.Spec.NodeName == node {
+			res = append(res, *pod.DeepCopy())
+		}
+	}
+	return res, nil
+}
+
+// AddNode 添加或者更新节点，会自动增加 ResourceVersion
+func (f *FakeClient) AddNode(node v1.Node) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	version := 0
+	if old, ok := f.Nodes[node.Name]; ok {
+		version, _ = strconv.Atoi(old.ResourceVersion)
+	}
+	node.ResourceVersion = strconv.Itoa(version + 1)
+	f.Nodes[node.Name] = node
+}
+
+// DeleteNode 删除节点
+func (f *FakeClient) DeleteNode(name string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.Nodes, name)
+}
+
+func (f *FakeClient) PatchNodeAnnotations(_ context.Context, name string, annotations map[string]string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	node, ok := f.Nodes[name]
+	if !ok {
+		return &StatusError{Code: http.StatusNotFound, Message: "node " + name + " not found"}
+	}
+	node = *node.DeepCopy()
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	for k, v := range annotations {
+		node.Annotations[k] = v
+	}
+	f.Nodes[name] = node
+	return nil
+}
+
+func (f *FakeClient) SetNodeCondition(_ context.Context, name string, condition v1.NodeCondition) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	node, ok := f.Nodes[name]
+	if !ok {
+		return &StatusError{Code: http.StatusNotFound, Message: "node " + name + " not found"}
+	}
+	node = *node.DeepCopy()
+	replaced := false
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == condition.Type {
+			node.Status.Conditions[i] = condition
+			replaced = true
+		}
+	}
+	if !replaced {
+		node.Status.Conditions = append(node.Status.Conditions, condition)
+	}
+	f.Nodes[name] = node
+	return nil
+}
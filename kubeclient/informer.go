@@ -0,0 +1,137 @@
+package kubeclient
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// NodeLister 列出集群中所有的 Node
+type NodeLister interface {
+	ListNodes(ctx context.Context) ([]v1.Node, error)
+}
+
+// NodeEventHandler 接收 Node 的变化
+type NodeEventHandler interface {
+	OnAdd(node *v1.Node)
+	OnUpdate(oldNode, newNode *v1.Node)
+	OnDelete(node *v1.Node)
+}
+
+// NodeSyncHandler handler 可以选择实现的接口，每次 list 产生的事件都分发完之后调用一次 OnSync，
+// handler 可以在这里统一处理，而不是在每个事件中重复计算
+type NodeSyncHandler interface {
+	OnSync()
+}
+
+// NodeInformer 监听 Node 的变化并通知给 handler
+type NodeInformer interface {
+	AddEventHandler(handler NodeEventHandler)
+	Run(stop <-chan struct{})
+	HasSynced() bool
+}
+
+// PollingNodeInformer 定期 list 所有 Node，与上一次的结果比较后产生事件
+// 与 list+watch 相比，每一轮都要从 apiserver 读取全部 Node，开销与节点数成正比，
+// 但是不需要处理 watch 断开、resourceVersion 过期之后的重新 list，也不会漏掉事件。
+// 只用来维护 agent ID 与节点名的对应关系，对实时性要求不高，节点很多时应该调大 node_watch.interval_seconds，
+// RESTClient.ListNodes 会分页读取，降低单次请求的内存占用
+type PollingNodeInformer struct {
+	lister   NodeLister
+	interval time.Duration
+
+	lock     sync.Mutex
+	handlers []NodeEventHandler
+	nodes    map[string]*v1.Node
+	synced   bool
+}
+
+// NewPollingNodeInformer 创建 informer，调用 Run 之后开始工作
+func NewPollingNodeInformer(lister NodeLister, interval time.Duration) *PollingNodeInformer {
+	return &PollingNodeInformer{
+		lister:   lister,
+		interval: interval,
+		nodes:    map[string]*v1.Node{},
+	}
+}
+
+func (i *PollingNodeInformer) AddEventHandler(handler NodeEventHandler) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.handlers = append(i.handlers, handler)
+}
+
+func (i *PollingNodeInformer) HasSynced() bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.synced
+}
+
+// Run 阻塞运行，直到 stop 被关闭
+func (i *PollingNodeInformer) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+	for {
+		if err := i.Resync(); err != nil {
+			log.Println("[err] list nodes:", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Resync 立即 list 一次并分发事件
+func (i *PollingNodeInformer) Resync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), i.interval)
+	defer cancel()
+	list, err := i.lister.ListNodes(ctx)
+	if err != nil {
+		return err
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	seen := make(map[string]bool, len(list))
+	changed := !i.synced
+	for idx := range list {
+		node := &list[idx]
+		seen[node.Name] = true
+		old, ok := i.nodes[node.Name]
+		i.nodes[node.Name] = node
+		if ok && old.ResourceVersion == node.ResourceVersion {
+			continue
+		}
+		changed = true
+		for _, handler := range i.handlers {
+			if !ok {
+				handler.OnAdd(node)
+			} else {
+				handler.OnUpdate(old, node)
+			}
+		}
+	}
+	for name, node := range i.nodes {
+		if seen[name] {
+			continue
+		}
+		changed = true
+		delete(i.nodes, name)
+		for _, handler := range i.handlers {
+			handler.OnDelete(node)
+		}
+	}
+	i.synced = true
+	if changed {
+		for _, handler := range i.handlers {
+			if h, ok := handler.(NodeSyncHandler); ok {
+				h.OnSync()
+			}
+		}
+	}
+	return nil
+}
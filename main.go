@@ -12,7 +12,7 @@ func main() {
 	// diskinfo()
 	// netinfo()
 	metric := &model.NodeMetric{}
-	c := collector.NewDefaultCollector()
+	c := collector.NewDefaultCollector(collector.Options{PerCore: true})
 	c.Collect(metric)
 	fmt.Printf("%+v\n", *metric)
 }
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthConfig 基于 bearer token 的认证，未开启时所有接口都不需要认证
+// scheduler 调用的 k8sextension 接口无法携带 token，通过客户端证书的 CN 区分身份，见 SchedulerNames
+type AuthConfig struct {
+	Enabled bool `json:"enabled"`
+	// NodeTokens token -> 节点 ID，只能上报该节点的数据
+	NodeTokens map[string]string `json:"node_tokens"`
+	// IngestTokens 可以上报任意节点数据的 token
+	IngestTokens []string `json:"ingest_tokens"`
+	// AdminTokens 可以修改 processor 配置，同时拥有只读权限
+	AdminTokens []string `json:"admin_tokens"`
+	// ReadTokens 只能访问查询接口
+	ReadTokens []string `json:"read_tokens"`
+	// SchedulerNames 可以调用 k8sextension 接口的客户端证书 CN，为空时不限制
+	// agent 的证书通常由同一个 CA 签发，只要求客户端证书时 agent 也可以调用 bind；
+	// 这些 CN 的证书也不能作为节点身份上报数据
+	SchedulerNames []string `json:"scheduler_names"`
+}
+
+func (c *AuthConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.AdminTokens) == 0 {
+		return fmt.Errorf("auth.admin_tokens must not be empty when auth is enabled")
+	}
+	for token, nodeID := range c.NodeTokens {
+		if token == "" || nodeID == "" {
+			return fmt.Errorf("auth.node_tokens: empty token or node id")
+		}
+	}
+	for _, name := range c.SchedulerNames {
+		if name == "" {
+			return fmt.Errorf("auth.scheduler_names: empty name")
+		}
+	}
+	return nil
+}
+
+// ctxKeyNodeID 认证之后允许上报的节点 ID，为空表示可以上报任意节点
+const ctxKeyNodeID = "auth_node_id"
+
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+func tokenIn(token string, tokens []string) bool {
+	found := false
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			found = true
+		}
+	}
+	return token != "" && found
+}
+
+func reject(c *gin.Context, status int, reason string) {
+	log.Printf("[audit] reject %s %s from %s: %s", c.Request.Method, c.Request.URL.Path, remoteIP(c), reason)
+	c.AbortWithStatusJSON(status, gin.H{"error": reason})
+}
+
+// requireIngest agent 上报数据的接口：节点 token 只能上报 :nodeid 为绑定节点的数据
+// certIdentity 为 true（agent 监听地址开启了 require_agent_client_cert）时，通过校验的客户端证书的 CN 同样视为节点 ID，
+// 同一个 CA 也会签发 scheduler 的证书，CN 在 SchedulerNames 中的证书不能作为节点身份
+func (c *AuthConfig) requireIngest(certIdentity bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !c.Enabled {
+			ctx.Next()
+			return
+		}
+		nodeID := ctx.Param("nodeid")
+		token := bearerToken(ctx)
+		if tokenIn(token, c.IngestTokens) {
+			ctx.Next()
+			return
+		}
+		var bound string
+		for t, id := range c.NodeTokens {
+			if tokenIn(token, []string{t}) {
+				bound = id
+			}
+		}
+		if bound == "" && certIdentity && ctx.Request.TLS != nil && len(ctx.Request.TLS.VerifiedChains) > 0 {
+			name := ctx.Request.TLS.VerifiedChains[0][0].Subject.CommonName
+			if c.isScheduler(name) {
+				reject(ctx, http.StatusForbidden, fmt.Sprintf("scheduler certificate %s can not report node data", name))
+				return
+			}
+			bound = name
+		}
+		if bound == "" {
+			reject(ctx, http.StatusUnauthorized, "missing or invalid ingest credential")
+			return
+		}
+		if bound != nodeID {
+			reject(ctx, http.StatusForbidden, fmt.Sprintf("credential of node %s can not report node %s", bound, nodeID))
+			return
+		}
+		ctx.Set(ctxKeyNodeID, bound)
+		ctx.Next()
+	}
+}
+
+func (c *AuthConfig) isScheduler(name string) bool {
+	for _, n := range c.SchedulerNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// requireScheduler k8sextension 接口：配置了 SchedulerNames 时，
+// 请求必须提供经过校验的客户端证书，并且 CN 在 SchedulerNames 中
+func (c *AuthConfig) requireScheduler(ctx *gin.Context) {
+	if !c.Enabled || len(c.SchedulerNames) == 0 {
+		ctx.Next()
+		return
+	}
+	if ctx.Request.TLS == nil || len(ctx.Request.TLS.VerifiedChains) == 0 {
+		reject(ctx, http.StatusUnauthorized, "missing scheduler client certificate")
+		return
+	}
+	name := ctx.Request.TLS.VerifiedChains[0][0].Subject.CommonName
+	if c.isScheduler(name) {
+		ctx.Next()
+		return
+	}
+	reject(ctx, http.StatusForbidden, fmt.Sprintf("client %s is not a scheduler", name))
+}
+
+// requireAdmin 修改配置的接口
+func (c *AuthConfig) requireAdmin(ctx *gin.Context) {
+	if c.Enabled && !tokenIn(bearerToken(ctx), c.AdminTokens) {
+		reject(ctx, http.StatusUnauthorized, "missing or invalid admin token")
+		return
+	}
+	ctx.Next()
+}
+
+// requireRead 查询接口，admin token 也可以访问
+func (c *AuthConfig) requireRead(ctx *gin.Context) {
+	token := bearerToken(ctx)
+	if c.Enabled && !tokenIn(token, c.ReadTokens) && !tokenIn(token, c.AdminTokens) {
+		reject(ctx, http.StatusUnauthorized, "missing or invalid read token")
+		return
+	}
+	ctx.Next()
+}
+
+// checkReportedNode 上报数据中的节点 ID 必须与认证的节点一致
+func checkReportedNode(c *gin.Context, reported string) bool {
+	if reported != c.Param("nodeid") {
+		reject(c, http.StatusBadRequest, fmt.Sprintf("node id %s in body does not match %s in path", reported, c.Param("nodeid")))
+		return false
+	}
+	if bound := c.GetString(ctxKeyNodeID); bound != "" && bound != reported {
+		reject(c, http.StatusForbidden, fmt.Sprintf("credential of node %s can not report node %s", bound, reported))
+		return false
+	}
+	return true
+}
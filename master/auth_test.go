@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireScheduler(t *testing.T) {
+	dir := writeTestCerts(t)
+	auth := AuthConfig{Enabled: true, AdminTokens: []string{"admin"}, SchedulerNames: []string{"kube-scheduler"}}
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	scheduler := engine.Group("/", requireClientCert, auth.requireScheduler)
+	scheduler.POST("/api/v1/k8sextension/bind", func(c *gin.Context) { c.Status(http.StatusOK) })
+	url := startTLSEngine(t, engine, testTLSConfig(dir)) + "/api/v1/k8sextension/bind"
+
+	tests := []struct {
+		cert string
+		want int
+	}{
+		{"scheduler", http.StatusOK},
+		// agent 的证书由同一个 CA 签发，但是不能调用 bind
+		{"agent-node-1", http.StatusForbidden},
+		{"", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if code := tlsStatus(t, dir, tt.cert, http.MethodPost, url); code != tt.want {
+			t.Errorf("cert %q: status %d, want %d", tt.cert, code, tt.want)
+		}
+	}
+}
+
+func TestValidateScheduler(t *testing.T) {
+	names := AuthConfig{Enabled: true, AdminTokens: []string{"admin"}, SchedulerNames: []string{"kube-scheduler"}}
+	withCA := TLSConfig{CertFile: "a.crt", KeyFile: "a.key", ClientCAFile: "ca.crt"}
+	tests := []struct {
+		name   string
+		config func(*Config)
+		ok     bool
+	}{
+		{"default", func(*Config) {}, true},
+		{"names without client ca", func(c *Config) { c.Auth = names }, false},
+		{"names with client ca", func(c *Config) { c.Auth, c.TLS = names, withCA }, true},
+		{"listener overrides tls", func(c *Config) {
+			c.Auth, c.TLS = names, withCA
+			c.Listeners.Scheduler = ListenerConfig{Addr: ":9443", TLS: &TLSConfig{}}
+		}, false},
+		{"listener overrides auth", func(c *Config) {
+			c.Auth = names
+			c.Listeners.Scheduler = ListenerConfig{Addr: ":9443", Auth: &AuthConfig{}}
+		}, true},
+		{"bind on all interfaces", func(c *Config) { c.Bind = true }, false},
+		{"bind on loopback", func(c *Config) { c.Bind, c.Addr = true, "127.0.0.1:8080" }, true},
+		{"bind on localhost listener", func(c *Config) {
+			c.Bind = true
+			c.Listeners.Scheduler = ListenerConfig{Addr: "localhost:8081"}
+		}, true},
+		{"bind on ipv6 loopback", func(c *Config) { c.Bind, c.Addr = true, "[::1]:8080" }, true},
+		{"bind with scheduler names", func(c *Config) { c.Bind, c.Auth, c.TLS = true, names, withCA }, true},
+		{"bind with auth but no scheduler names", func(c *Config) {
+			c.Bind, c.TLS = true, withCA
+			c.Auth = AuthConfig{Enabled: true, AdminTokens: []string{"admin"}}
+		}, false},
+		{"bind on public listener with loopback main addr", func(c *Config) {
+			c.Bind, c.Addr = true, "127.0.0.1:8080"
+			c.Listeners.Scheduler = ListenerConfig{Addr: "0.0.0.0:8081"}
+		}, false},
+	}
+	for _, tt := range tests {
+		c := defaultConfig()
+		tt.config(c)
+		if err := c.validateScheduler(); (err == nil) != tt.ok {
+			t.Errorf("%s: validateScheduler() = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+// certState 模拟通过校验的客户端证书
+func certState(cn string) *tls.ConnectionState {
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}}
+}
+
+// authStatus 使用 token 和客户端证书 cn 请求 handler，两者为空时不提供
+func authStatus(engine *gin.Engine, method, path, token, cn string) int {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if cn != "" {
+		req.TLS = certState(cn)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w.Code
+}
+
+func testAuthConfig() AuthConfig {
+	return AuthConfig{
+		Enabled:        true,
+		NodeTokens:     map[string]string{"node-1-token": "node-1"},
+		IngestTokens:   []string{"ingest"},
+		AdminTokens:    []string{"admin"},
+		ReadTokens:     []string{"read"},
+		SchedulerNames: []string{"kube-scheduler"},
+	}
+}
+
+func TestRequireIngest(t *testing.T) {
+	auth := testAuthConfig()
+	gin.SetMode(gin.TestMode)
+	newEngine := func(certIdentity bool) *gin.Engine {
+		engine := gin.New()
+		engine.PUT("/api/v1/agenthealth/:nodeid", auth.requireIngest(certIdentity), func(c *gin.Context) {
+			c.String(http.StatusOK, c.GetString(ctxKeyNodeID))
+		})
+		return engine
+	}
+	tests := []struct {
+		name         string
+		certIdentity bool
+		node         string
+		token, cn    string
+		want         int
+	}{
+		{"node token own node", false, "node-1", "node-1-token", "", http.StatusOK},
+		{"node token other node", false, "node-2", "node-1-token", "", http.StatusForbidden},
+		{"ingest token any node", false, "node-2", "ingest", "", http.StatusOK},
+		{"admin token", false, "node-1", "admin", "", http.StatusUnauthorized},
+		{"invalid token", false, "node-1", "wrong", "", http.StatusUnauthorized},
+		{"no credential", false, "node-1", "", "", http.StatusUnauthorized},
+		{"cert own node", true, "node-1", "", "node-1", http.StatusOK},
+		{"cert other node", true, "node-2", "", "node-1", http.StatusForbidden},
+		{"cert without cert identity", false, "node-1", "", "node-1", http.StatusUnauthorized},
+		{"scheduler cert", true, "kube-scheduler", "", "kube-scheduler", http.StatusForbidden},
+		{"token takes precedence over cert", true, "node-1", "node-1-token", "node-2", http.StatusOK},
+	}
+	for _, tt := range tests {
+		code := authStatus(newEngine(tt.certIdentity), http.MethodPut, "/api/v1/agenthealth/"+tt.node, tt.token, tt.cn)
+		if code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	disabled := AuthConfig{}
+	engine := gin.New()
+	engine.PUT("/api/v1/agenthealth/:nodeid", disabled.requireIngest(false), func(c *gin.Context) { c.Status(http.StatusOK) })
+	if code := authStatus(engine, http.MethodPut, "/api/v1/agenthealth/node-1", "", ""); code != http.StatusOK {
+		t.Errorf("auth disabled: status %d", code)
+	}
+}
+
+func TestRequireAdminAndRead(t *testing.T) {
+	auth := testAuthConfig()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	engine.GET("/api/v1/processors", auth.requireRead, ok)
+	engine.PUT("/api/v1/processors", auth.requireAdmin, ok)
+	tests := []struct {
+		method, token string
+		want          int
+	}{
+		{http.MethodGet, "read", http.StatusOK},
+		{http.MethodGet, "admin", http.StatusOK},
+		{http.MethodGet, "ingest", http.StatusUnauthorized},
+		{http.MethodGet, "", http.StatusUnauthorized},
+		{http.MethodPut, "admin", http.StatusOK},
+		{http.MethodPut, "read", http.StatusUnauthorized},
+		{http.MethodPut, "node-1-token", http.StatusUnauthorized},
+		{http.MethodPut, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if code := authStatus(engine, tt.method, "/api/v1/processors", tt.token, ""); code != tt.want {
+			t.Errorf("%s with token %q: status %d, want %d", tt.method, tt.token, code, tt.want)
+		}
+	}
+}
+
+func TestCheckReportedNode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		bound, reported string
+		want            int
+	}{
+		{"", "node-1", http.StatusOK},
+		{"node-1", "node-1", http.StatusOK},
+		{"", "node-2", http.StatusBadRequest},
+		{"node-2", "node-1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		engine := gin.New()
+		engine.PUT("/:nodeid", func(c *gin.Context) {
+			if tt.bound != "" {
+				c.Set(ctxKeyNodeID, tt.bound)
+			}
+			if checkReportedNode(c, tt.reported) {
+				c.Status(http.StatusOK)
+			}
+		})
+		if code := authStatus(engine, http.MethodPut, "/node-1", "", ""); code != tt.want {
+			t.Errorf("bound %q reported %q: status %d, want %d", tt.bound, tt.reported, code, tt.want)
+		}
+	}
+}
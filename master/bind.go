@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"systeminfoagent/kubeclient"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulerapi "k8s.io/kube-scheduler/extender/v1"
+)
+
+// maxPlacements 最多保存的绑定记录数
+const maxPlacements = 10000
+
+var bindTimeout = time.Second * 10
+
+// Placement 一次绑定的记录，用于将之后节点上报的数据与调度结果对应起来
+type Placement struct {
+	PodUID       string    `json:"pod_uid"`
+	PodNamespace string    `json:"pod_namespace"`
+	PodName      string    `json:"pod_name"`
+	Node         string    `json:"node"`
+	BoundAt      time.Time `json:"bound_at"`
+	// MetricTimestamp 绑定时节点最新一条数据的时间，节点没有数据时为零值
+	MetricTimestamp time.Time `json:"metric_timestamp"`
+}
+
+var placements []Placement
+var placementLock sync.Mutex
+
+func recordPlacement(p Placement) {
+	placementLock.Lock()
+	defer placementLock.Unlock()
+	placements = append(placements, p)
+	if len(placements) > maxPlacements {
+		placements = append([]Placement(nil), placements[len(placements)-maxPlacements:]...)
+	}
+}
+
+// getPlacements 返回绑定到 node 上的记录，node 为空时返回全部
+func getPlacements(node string) []Placement {
+	placementLock.Lock()
+	defer placementLock.Unlock()
+	res := []Placement{}
+	for _, p := range placements {
+		if node == "" || p.Node == node {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// bind 通过 binder 将 pod 绑定到节点上，成功后记录下来
+func bind(binder kubeclient.Binder, args schedulerapi.ExtenderBindingArgs) *schedulerapi.ExtenderBindingResult {
+	binding := &v1.Binding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: args.PodNamespace, Name: args.PodName, UID: args.PodUID},
+		Target:     v1.ObjectReference{Kind: "Node", Name: args.Node},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), bindTimeout)
+	defer cancel()
+	if err := binder.Bind(ctx, binding); err != nil {
+		log.Printf("[err] bind %s/%s to %s: %v", args.PodNamespace, args.PodName, args.Node, err)
+		return &schedulerapi.ExtenderBindingResult{Error: err.Error()}
+	}
+	placement := Placement{
+		PodUID:       string(args.PodUID),
+		PodNamespace: args.PodNamespace,
+		PodName:      args.PodName,
+		Node:         args.Node,
+		BoundAt:      time.Now(),
+	}
+	if latestMetric, ok := getNodeLatestMetric(args.Node); ok {
+		placement.MetricTimestamp = latestMetric.RawMetric.Timestamp
+	}
+	recordPlacement(placement)
+	log.Printf("[info] bound %s/%s to %s", args.PodNamespace, args.PodName, args.Node)
+	return &schedulerapi.ExtenderBindingResult{}
+}
+
+// bindFunc POST /api/v1/k8sextension/bind
+func bindFunc(binder kubeclient.Binder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var args schedulerapi.ExtenderBindingArgs
+		if err := c.ShouldBindJSON(&args); err != nil {
+			log.Printf("[err] json decode err:%v", err)
+			c.JSON(http.StatusOK, &schedulerapi.ExtenderBindingResult{Error: "decode binding args: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, bind(binder, args))
+	}
+}
+
+// placementsFunc GET /api/v1/placements?node=xxx
+func placementsFunc(c *gin.Context) {
+	c.JSON(http.StatusOK, getPlacements(c.Query("node")))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"systeminfoagent/kubeclient"
+
+	"github.com/gin-gonic/gin"
+	schedulerapi "k8s.io/kube-scheduler/extender/v1"
+)
+
+func resetPlacements(t *testing.T) {
+	t.Helper()
+	placementLock.Lock()
+	placements = nil
+	placementLock.Unlock()
+	t.Cleanup(func() {
+		placementLock.Lock()
+		placements = nil
+		placementLock.Unlock()
+	})
+}
+
+func postBind(t *testing.T, binder kubeclient.Binder, body []byte) *schedulerapi.ExtenderBindingResult {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/bind", bindFunc(binder))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/bind", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	var res schedulerapi.ExtenderBindingResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return &res
+}
+
+func bindingArgs(t *testing.T) []byte {
+	t.Helper()
+	body, err := json.Marshal(schedulerapi.ExtenderBindingArgs{
+		PodName:      "web-0",
+		PodNamespace: "default",
+		PodUID:       "uid-1",
+		Node:         "node-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestBind(t *testing.T) {
+	resetPlacements(t)
+	client := kubeclient.NewFakeClient()
+	if res := postBind(t, client, bindingArgs(t)); res.Error != "" {
+		t.Fatalf("unexpected error %q", res.Error)
+	}
+	if len(client.Bindings) != 1 {
+		t.Fatalf("got %d bindings", len(client.Bindings))
+	}
+	b := client.Bindings[0]
+	if b.Namespace != "default" || b.Name != "web-0" || b.UID != "uid-1" || b.Target.Kind != "Node" || b.Target.Name != "node-1" {
+		t.Errorf("unexpected binding %+v", b)
+	}
+	got := getPlacements("node-1")
+	if len(got) != 1 || got[0].PodUID != "uid-1" || got[0].BoundAt.IsZero() {
+		t.Errorf("unexpected placements %+v", got)
+	}
+	if other := getPlacements("node-2"); len(other) != 0 {
+		t.Errorf("unexpected placements on node-2 %+v", other)
+	}
+}
+
+func TestBindError(t *testing.T) {
+	resetPlacements(t)
+	client := kubeclient.NewFakeClient()
+	client.BindError = errors.New("pod already bound")
+	res := postBind(t, client, bindingArgs(t))
+	if res.Error != "pod already bound" {
+		t.Errorf("error = %q", res.Error)
+	}
+	if got := getPlacements(""); len(got) != 0 {
+		t.Errorf("failed binding was recorded: %+v", got)
+	}
+}
+
+func TestBindDecodeError(t *testing.T) {
+	resetPlacements(t)
+	client := kubeclient.NewFakeClient()
+	if res := postBind(t, client, []byte("{")); res.Error == "" {
+		t.Error("expected a decode error")
+	}
+	if len(client.Bindings) != 0 {
+		t.Errorf("unexpected bindings %+v", client.Bindings)
+	}
+}
+
+func TestRecordPlacementLimit(t *testing.T) {
+	resetPlacements(t)
+	for i := 0; i < maxPlacements+10; i++ {
+		recordPlacement(Placement{PodName: "p", Node: "n"})
+	}
+	if got := len(getPlacements("")); got != maxPlacements {
+		t.Errorf("kept %d placements, want %d", got, maxPlacements)
+	}
+}
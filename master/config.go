@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"systeminfoagent/kubeclient"
+	"systeminfoagent/nodeexporter"
+	"systeminfoagent/policy"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Config master 的配置，通过 -config 指定的 json 文件加载
+type Config struct {
+	Addr         string             `json:"addr"`
+	TLS          TLSConfig          `json:"tls"`
+	Auth         AuthConfig         `json:"auth"`
+	NodeExporter NodeExporterConfig `json:"node_exporter"`
+	// Profiles 命名的打分权重和参数，pod 可以通过 annotation 或者 Rules 选择
+	// Rules 按 namespace 和 label 为 pod 选择 profile
+	// 设置了权重的 profile 默认按权重加权平均（scoring 为 2），scoring 为 1 时权重不改变节点的排序，见 processor.ScoringVersion
+	policy.Policy
+	// Kubernetes 访问 apiserver 的配置，server 为空时使用 in-cluster 配置
+	Kubernetes kubeclient.Config `json:"kubernetes"`
+	// Bind 为 true 时 master 同时作为 binder，提供 bind 接口
+	// scheduler 接口需要只监听回环地址，或者通过 auth.scheduler_names 限制只有 scheduler 的证书可以调用
+	Bind bool `json:"bind"`
+	// NodeWatch 监听 k8s 中的节点，将 agent ID 与节点名对应起来
+	NodeWatch NodeWatchConfig `json:"node_watch"`
+	// Extender scheduler 访问 master 的方式，用于 schedconfig 子命令
+	Extender ExtenderConfig `json:"extender"`
+	// Publish 将节点的分数和主要指标写回 Node 的 annotation 和 condition，需要同时开启 node_watch
+	Publish PublishConfig `json:"publish"`
+	// Listeners 为 scheduler、agent 和管理接口单独配置监听地址，未配置的接口仍然使用 Addr
+	Listeners ListenersConfig `json:"listeners"`
+}
+
+// NodeWatchConfig 节点监听的配置
+type NodeWatchConfig struct {
+	Enabled         bool `json:"enabled"`
+	IntervalSeconds int  `json:"interval_seconds"`
+}
+
+// NodeExporterConfig 直接从 node_exporter 抓取数据，替代 agent 上报
+type NodeExporterConfig struct {
+	Enabled         bool `json:"enabled"`
+	IntervalSeconds int  `json:"interval_seconds"`
+	// TimeoutSeconds 单次抓取的超时时间，与抓取间隔分开配置，默认 5 秒
+	TimeoutSeconds int                   `json:"timeout_seconds"`
+	Targets        []nodeexporter.Target `json:"targets"`
+	// DiscoverPort 不为 0 时，对监听到的每个节点抓取 http://<InternalIP>:<DiscoverPort>/metrics，
+	// 需要同时开启 node_watch
+	DiscoverPort int `json:"discover_port"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Addr: ":8080",
+		NodeExporter: NodeExporterConfig{
+			IntervalSeconds: 1,
+			TimeoutSeconds:  int(nodeexporter.DefaultTimeout / time.Second),
+		},
+		NodeWatch: NodeWatchConfig{
+			IntervalSeconds: 10,
+		},
+		Extender: ExtenderConfig{
+			Weight:         1,
+			TimeoutSeconds: 5,
+		},
+		Publish: PublishConfig{
+			IntervalSeconds:      60,
+			ScoreDelta:           5,
+			HighDiskUsagePercent: 90,
+		},
+	}
+}
+
+func loadConfig(path string) (*Config, error) {
+	config := defaultConfig()
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %v", err)
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("parse config: %v", err)
+	}
+	if err := config.TLS.validate(); err != nil {
+		return nil, fmt.Errorf("parse config: %v", err)
+	}
+	if err := config.Auth.validate(); err != nil {
+		return nil, fmt.Errorf("parse config: %v", err)
+	}
+	if err := config.Listeners.validate(config.Addr); err != nil {
+		return nil, fmt.Errorf("parse config: %v", err)
+	}
+	if err := config.validateScheduler(); err != nil {
+		return nil, fmt.Errorf("parse config: %v", err)
+	}
+	if config.NodeExporter.IntervalSeconds <= 0 {
+		return nil, fmt.Errorf("parse config: node_exporter.interval_seconds must be positive")
+	}
+	if config.NodeExporter.TimeoutSeconds <= 0 {
+		return nil, fmt.Errorf("parse config: node_exporter.timeout_seconds must be positive")
+	}
+	for _, target := range config.NodeExporter.Targets {
+		if target.NodeID == "" || target.URL == "" {
+			return nil, fmt.Errorf("parse config: node_exporter target needs node_id and url")
+		}
+	}
+	if config.NodeWatch.IntervalSeconds <= 0 {
+		return nil, fmt.Errorf("parse config: node_watch.interval_seconds must be positive")
+	}
+	if config.NodeExporter.DiscoverPort != 0 && !config.NodeWatch.Enabled {
+		return nil, fmt.Errorf("parse config: node_exporter.discover_port requires node_watch")
+	}
+	if config.Extender.Weight <= 0 || config.Extender.TimeoutSeconds <= 0 {
+		return nil, fmt.Errorf("parse config: extender.weight and extender.timeout_seconds must be positive")
+	}
+	if config.Publish.Enabled && !config.NodeWatch.Enabled {
+		return nil, fmt.Errorf("parse config: publish requires node_watch")
+	}
+	if config.Publish.IntervalSeconds <= 0 {
+		return nil, fmt.Errorf("parse config: publish.interval_seconds must be positive")
+	}
+	if err := config.Policy.Validate(); err != nil {
+		return nil, fmt.Errorf("parse config: %v", err)
+	}
+	return config, nil
+}
+
+// exporterTargets 返回配置中的 target 以及从监听到的节点发现的 target
+func exporterTargets(config NodeExporterConfig) []nodeexporter.Target {
+	targets := append([]nodeexporter.Target(nil), config.Targets...)
+	if config.DiscoverPort == 0 || reconciler == nil {
+		return targets
+	}
+	configured := map[string]bool{}
+	for _, target := range targets {
+		configured[target.NodeID] = true
+	}
+	for _, node := range reconciler.Nodes() {
+		if configured[node.Name] {
+			continue
+		}
+		for _, addr := range node.Status.Addresses {
+			if addr.Type == v1.NodeInternalIP {
+				targets = append(targets, nodeexporter.Target{
+					NodeID: node.Name,
+					URL:    fmt.Sprintf("http://%s/metrics", net.JoinHostPort(addr.Address, strconv.Itoa(config.DiscoverPort))),
+				})
+				break
+			}
+		}
+	}
+	return targets
+}
@@ -8,29 +8,60 @@ import (
 var dataMap = map[string]*model.NodeInfoRecord{}
 var lock sync.Mutex
 
-func getAllNodeLatestMetrics() []*model.NodeFullMetric {
+// getLatestMetrics 返回所有节点最新一条数据的拷贝，按照上报时使用的 ID 索引
+func getLatestMetrics() map[string]model.NodeFullMetric {
 	lock.Lock()
 	defer lock.Unlock()
-	var res []*model.NodeFullMetric
-	for _, v := range dataMap {
-		res = append(res, &(v.Metrics[len(v.Metrics)-1]))
+	res := make(map[string]model.NodeFullMetric, len(dataMap))
+	for id, record := range dataMap {
+		if len(record.Metrics) > 0 {
+			res[id] = record.Metrics[len(record.Metrics)-1]
+		}
 	}
 	return res
 }
 
-func getRecord(nodeid string) (*model.NodeInfoRecord, bool, error) {
+// getLatestMetric 返回节点最新一条数据的拷贝
+func getLatestMetric(nodeid string) (model.NodeFullMetric, bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	record, ok := dataMap[nodeid]
+	if !ok || len(record.Metrics) == 0 {
+		return model.NodeFullMetric{}, false
+	}
+	return record.Metrics[len(record.Metrics)-1], true
+}
+
+// updateRecord 在锁内修改节点的记录，记录不存在时会新建
+func updateRecord(nodeid string, fn func(*model.NodeInfoRecord)) error {
 	lock.Lock()
 	defer lock.Unlock()
 	record, ok := dataMap[nodeid]
 	if !ok {
 		record = &model.NodeInfoRecord{ID: nodeid}
+		dataMap[nodeid] = record
+	}
+	fn(record)
+	return nil
+}
+
+// getAgentAddresses 返回所有上报过数据的 agent 及其最新的来源地址
+func getAgentAddresses() map[string]string {
+	lock.Lock()
+	defer lock.Unlock()
+	res := make(map[string]string, len(dataMap))
+	for id, record := range dataMap {
+		var address string
+		if len(record.Metrics) > 0 {
+			address = record.Metrics[len(record.Metrics)-1].NodeInfo.Address
+		}
+		res[id] = address
 	}
-	return record, ok, nil
+	return res
 }
 
-func saveRecord(nodeid string, record *model.NodeInfoRecord) error {
+func deleteRecord(nodeid string) {
 	lock.Lock()
 	defer lock.Unlock()
-	dataMap[nodeid] = record
-	return nil
+	delete(dataMap, nodeid)
 }
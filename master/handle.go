@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"systeminfoagent/model"
+	"systeminfoagent/policy"
 	"systeminfoagent/processor"
 	"time"
 
@@ -19,37 +20,23 @@ var metricProcessor = processor.NewScoreProcessor()
 var offlineTimeBound = time.Second * 5
 
 func processdata(rawMetric *model.NodeMetric) {
-	// 查询往期的记录
-	record, _, err := getRecord(rawMetric.NodeInfo.ID)
-	if err != nil {
-		log.Println("[err] get record", err)
-		return
-	}
-	defer func() {
-		// 存储数据
-		if err := saveRecord(rawMetric.NodeInfo.ID, record); err != nil {
-			log.Println("[err] save record", err)
-			return
-		}
-	}()
+	// 查询往期的记录并在锁内完成更新，避免打分时读到更新了一半的数据
+	err := updateRecord(rawMetric.NodeInfo.ID, func(record *model.NodeInfoRecord) {
+		record.Metrics = append(record.Metrics, model.NodeFullMetric{
+			RawMetric:  *rawMetric,
+			NodeInfo:   rawMetric.NodeInfo,
+			Statistics: model.Statistics{},
+		})
 
-	record.Metrics = append(record.Metrics, model.NodeFullMetric{
-		RawMetric:  *rawMetric,
-		NodeInfo:   rawMetric.NodeInfo,
-		Statistics: model.Statistics{},
-	})
+		// 判断节点是否下线过，如果是，则计算时长
+		checkIfOffline(record)
 
-	// 判断节点是否下线过，如果是，则计算时长
-	checkIfOffline(record)
-
-	// 判断数据是否合法，如果是，计算计算标准差平均值；如果不是，则使用上一次的数据
-	for _, processor := range processor.ProcessorMap {
-		processor.N(record)
-		processor.Even(record)
-		processor.Variance(record)
+		// 判断数据是否合法，如果是，计算计算标准差平均值；如果不是，则使用上一次的数据
+		processor.UpdateStatistics(record)
+	})
+	if err != nil {
+		log.Println("[err] update record", err)
 	}
-	// fmt.Printf("%+v, %+v\n", record.Metrics[len(record.Metrics)-1].Statistics.CPU, record.Metrics[len(record.Metrics)-1].RawMetric.CPU.Idle)
-	// fmt.Printf("%+v, %+v, %+v\n", record.DownDuration.Seconds(), record.Metrics[len(record.Metrics)-1].Statistics, record.Metrics[len(record.Metrics)-1].RawMetric)
 }
 
 func checkIfOffline(record *model.NodeInfoRecord) {
@@ -84,3 +71,49 @@ func priorityFunc(c *gin.Context) {
 		c.Writer.Flush()
 	}
 }
+
+// explainFunc GET /api/v1/explain/:nodeid 按照当前配置解释节点的打分过程
+func explainFunc(c *gin.Context) {
+	nodeID := c.Param("nodeid")
+	latestMetric, ok := getNodeLatestMetric(nodeID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no metric of node " + nodeID})
+		return
+	}
+	c.JSON(http.StatusOK, metricProcessor.Explain(&latestMetric, processor.Current(), processor.PodRequest{}))
+}
+
+// explainArgsFunc POST /api/v1/explain 使用与 prioritize 相同的请求，返回每个节点的打分过程
+// 以及 pod 匹配到的 profile 和规则
+func explainArgsFunc(c *gin.Context) {
+	var extendArgs schedulerapi.ExtenderArgs
+	if err := c.BindJSON(&extendArgs); err != nil {
+		log.Printf("[err] json decode err:%v", err)
+		return
+	}
+	c.JSON(http.StatusOK, explain(extendArgs))
+}
+
+// metricFunc GET /api/v1/metrics/:nodeid 返回节点最新的数据，nodeid 可以是 k8s 节点名或者 agent ID
+func metricFunc(c *gin.Context) {
+	nodeID := c.Param("nodeid")
+	latestMetric, ok := getNodeLatestMetric(nodeID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no metric of node " + nodeID})
+		return
+	}
+	c.JSON(http.StatusOK, latestMetric)
+}
+
+// metricsFunc GET /api/v1/metrics，返回所有节点最新的数据，按照 k8s 节点名索引
+func metricsFunc(c *gin.Context) {
+	c.JSON(http.StatusOK, getNodeLatestMetrics())
+}
+
+// policyFunc GET /api/v1/policy
+func policyFunc(c *gin.Context) {
+	c.JSON(http.StatusOK, policy.Snapshot{
+		Config: processor.Current().Status(),
+		Policy: masterConfig.Policy,
+	})
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListenerConfig 单独的监听地址，Addr 为空时对应的接口使用默认的 Addr
+// TLS 和 Auth 为空时沿用顶层的配置
+type ListenerConfig struct {
+	Addr string      `json:"addr"`
+	TLS  *TLSConfig  `json:"tls"`
+	Auth *AuthConfig `json:"auth"`
+	// ReadTimeoutSeconds 等为 0 时不限制
+	ReadTimeoutSeconds  int `json:"read_timeout_seconds"`
+	WriteTimeoutSeconds int `json:"write_timeout_seconds"`
+	IdleTimeoutSeconds  int `json:"idle_timeout_seconds"`
+	// MaxConcurrent 同时处理的请求数，超过时直接返回 503，为 0 时不限制
+	MaxConcurrent int `json:"max_concurrent"`
+}
+
+// ListenersConfig 按流量类型拆分监听地址，避免 agent 的大量上报影响 scheduler 的调用，
+// 也可以只在内网地址上暴露管理接口
+type ListenersConfig struct {
+	// Scheduler k8sextension 接口
+	Scheduler ListenerConfig `json:"scheduler"`
+	// Agent agent 上报数据的接口
+	Agent ListenerConfig `json:"agent"`
+	// Admin 查询和修改配置的接口
+	Admin ListenerConfig `json:"admin"`
+}
+
+func (l *ListenerConfig) validate(name string) error {
+	if l.Addr == "" {
+		if l.TLS != nil || l.Auth != nil || l.MaxConcurrent != 0 ||
+			l.ReadTimeoutSeconds != 0 || l.WriteTimeoutSeconds != 0 || l.IdleTimeoutSeconds != 0 {
+			return fmt.Errorf("listeners.%s: addr is required", name)
+		}
+		return nil
+	}
+	if l.MaxConcurrent < 0 || l.ReadTimeoutSeconds < 0 || l.WriteTimeoutSeconds < 0 || l.IdleTimeoutSeconds < 0 {
+		return fmt.Errorf("listeners.%s: timeouts and max_concurrent must not be negative", name)
+	}
+	if l.TLS != nil {
+		if err := l.TLS.validate(); err != nil {
+			return fmt.Errorf("listeners.%s: %v", name, err)
+		}
+	}
+	if l.Auth != nil {
+		if err := l.Auth.validate(); err != nil {
+			return fmt.Errorf("listeners.%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// security 返回监听地址实际使用的 TLS 和认证配置，未单独配置时沿用顶层的配置
+func (l *ListenerConfig) security(config *Config) (TLSConfig, AuthConfig) {
+	tlsConfig, auth := config.TLS, config.Auth
+	if l.Addr == "" {
+		return tlsConfig, auth
+	}
+	if l.TLS != nil {
+		tlsConfig = *l.TLS
+	}
+	if l.Auth != nil {
+		auth = *l.Auth
+	}
+	return tlsConfig, auth
+}
+
+// validateScheduler 检查 scheduler 接口实际使用的 TLS 和认证配置是否能够配合
+func (c *Config) validateScheduler() error {
+	tlsConfig, auth := c.Listeners.Scheduler.security(c)
+	restricted := auth.Enabled && len(auth.SchedulerNames) > 0
+	if restricted && tlsConfig.ClientCAFile == "" {
+		return fmt.Errorf("auth.scheduler_names requires tls.client_ca_file on the scheduler listener")
+	}
+	// bind 使用 master 的 apiserver 凭证绑定 pod，只允许 scheduler 调用
+	addr := c.Addr
+	if c.Listeners.Scheduler.Addr != "" {
+		addr = c.Listeners.Scheduler.Addr
+	}
+	if c.Bind && !restricted && !isLoopback(addr) {
+		return fmt.Errorf("bind requires the scheduler listener %s to listen on a loopback address, or auth.scheduler_names with client certificates", addr)
+	}
+	return nil
+}
+
+// isLoopback addr 是否只监听回环地址，host 为空时监听所有地址
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (c *ListenersConfig) validate(addr string) error {
+	all := []struct {
+		name     string
+		listener *ListenerConfig
+	}{{"scheduler", &c.Scheduler}, {"agent", &c.Agent}, {"admin", &c.Admin}}
+	seen := map[string]string{}
+	for _, l := range all {
+		if err := l.listener.validate(l.name); err != nil {
+			return err
+		}
+		if l.listener.Addr == "" {
+			// 未单独配置的接口使用默认的 Addr
+			seen[addr] = "addr"
+		}
+	}
+	for _, l := range all {
+		if l.listener.Addr == "" {
+			continue
+		}
+		if other, ok := seen[l.listener.Addr]; ok {
+			return fmt.Errorf("listeners.%s: addr %s is already used by %s", l.name, l.listener.Addr, other)
+		}
+		seen[l.listener.Addr] = "listeners." + l.name
+	}
+	return nil
+}
+
+// listener 一个监听地址以及注册在上面的接口
+type listener struct {
+	config ListenerConfig
+	tls    TLSConfig
+	auth   AuthConfig
+	engine *gin.Engine
+}
+
+// remoteIP 返回 TCP 连接的对端地址
+// 没有配置可信的代理，X-Forwarded-For 和 X-Real-IP 可以被任意客户端伪造，不能用 c.ClientIP()
+func remoteIP(c *gin.Context) string {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		return c.Request.RemoteAddr
+	}
+	return host
+}
+
+// listeners 三类接口所在的监听地址，未单独配置的类型共用默认监听地址
+type listeners struct {
+	scheduler *listener
+	agent     *listener
+	admin     *listener
+}
+
+func newListeners(config *Config) *listeners {
+	main := &listener{
+		config: ListenerConfig{Addr: config.Addr},
+		tls:    config.TLS,
+		auth:   config.Auth,
+		engine: gin.New(),
+	}
+	pick := func(l ListenerConfig) *listener {
+		if l.Addr == "" {
+			return main
+		}
+		ln := &listener{config: l, engine: gin.New()}
+		ln.tls, ln.auth = l.security(config)
+		if l.MaxConcurrent > 0 {
+			ln.engine.Use(limitConcurrency(l.MaxConcurrent))
+		}
+		return ln
+	}
+	return &listeners{
+		scheduler: pick(config.Listeners.Scheduler),
+		agent:     pick(config.Listeners.Agent),
+		admin:     pick(config.Listeners.Admin),
+	}
+}
+
+// serve 启动所有用到的监听地址，任意一个退出时返回错误
+// 三类接口都单独配置了地址时不再监听默认的 Addr
+func (ls *listeners) serve() error {
+	var all []*listener
+	seen := map[*listener]bool{}
+	for _, l := range []*listener{ls.scheduler, ls.agent, ls.admin} {
+		if !seen[l] {
+			seen[l] = true
+			all = append(all, l)
+		}
+	}
+	errs := make(chan error, len(all))
+	for _, l := range all {
+		tlsConfig, err := l.tls.serverTLSConfig()
+		if err != nil {
+			return err
+		}
+		server := &http.Server{
+			Addr:         l.config.Addr,
+			Handler:      l.engine,
+			ReadTimeout:  time.Duration(l.config.ReadTimeoutSeconds) * time.Second,
+			WriteTimeout: time.Duration(l.config.WriteTimeoutSeconds) * time.Second,
+			IdleTimeout:  time.Duration(l.config.IdleTimeoutSeconds) * time.Second,
+		}
+		go func(server *http.Server, tlsConfig *tls.Config) {
+			errs <- serve(server, tlsConfig)
+		}(server, tlsConfig)
+	}
+	return <-errs
+}
+
+// limitConcurrency 超过 max 个请求同时处理时返回 503，由调用方重试
+func limitConcurrency(max int) gin.HandlerFunc {
+	sem := make(chan struct{}, max)
+	return func(c *gin.Context) {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			c.Next()
+		default:
+			log.Printf("[warn] too many concurrent requests, reject %s %s", c.Request.Method, c.Request.URL.Path)
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+		}
+	}
+}
@@ -1,53 +1,139 @@
 package main
 
 import (
+	"flag"
 	"log"
-	"math"
 	"net/http"
+	"os"
 	"strconv"
+	"systeminfoagent/kubeclient"
 	"systeminfoagent/model"
+	"systeminfoagent/nodeexporter"
 	"systeminfoagent/processor"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+var masterConfig = defaultConfig()
+
 func main() {
-	r := gin.New()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "schedconfig":
+			os.Exit(schedConfigCmd(os.Args[2:]))
+		case "validate-schedconfig":
+			os.Exit(validateSchedConfigCmd(os.Args[2:]))
+		case "gencerts":
+			os.Exit(genCertsCmd(os.Args[2:]))
+		}
+	}
+	configPath := flag.String("config", "", "path of the json config file")
+	flag.Parse()
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	masterConfig = config
+
+	ls := newListeners(config)
 	ch := make(chan *model.NodeMetric)
-	r.PUT("/api/v1/agenthealth/:nodeid", func(c *gin.Context) {
-		// nodeID := c.Param("nodeid")
+	agentRoutes := ls.agent.engine.Group("/api/v1/agenthealth")
+	if ls.agent.tls.RequireAgentClientCert {
+		agentRoutes.Use(requireClientCert)
+	}
+	agentRoutes.Use(ls.agent.auth.requireIngest(ls.agent.tls.RequireAgentClientCert))
+	agentRoutes.PUT("/:nodeid", func(c *gin.Context) {
 		rawMetric := &model.NodeMetric{}
 		if err := c.BindJSON(rawMetric); err != nil {
 			log.Println("[err] parse json:", err)
 			c.Status(http.StatusInternalServerError)
 			return
 		}
-		// log.Println(nodeID, *rawMetric)
+		if ls.agent.auth.Enabled && !checkReportedNode(c, rawMetric.NodeInfo.ID) {
+			return
+		}
+		rawMetric.NodeInfo.Address = remoteIP(c)
 		ch <- rawMetric
 	})
-	r.POST("/api/v1/k8sextension/prioritize", func(c *gin.Context) {
+	scheduler := ls.scheduler.engine.Group("/")
+	if ls.scheduler.tls.RequireSchedulerClientCert {
+		scheduler.Use(requireClientCert)
+	}
+	scheduler.Use(ls.scheduler.auth.requireScheduler)
+	scheduler.POST("/api/v1/k8sextension/prioritize", func(c *gin.Context) {
 		log.Println("[debug] access priority")
 		priorityFunc(c)
 	})
-	r.PUT("/api/v1/processor/:id/:weight", func(c *gin.Context) {
+	var kubeClient *kubeclient.RESTClient
+	// 没有 client 时无法根据 UID 计算 nodeCacheCapable 的 victims 释放的资源，这些节点不做过滤
+	var podLister kubeclient.PodLister
+	if config.Bind || config.NodeWatch.Enabled {
+		kubeClient, err = kubeclient.NewRESTClient(config.Kubernetes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		podLister = kubeClient
+	}
+	scheduler.POST("/api/v1/k8sextension/preempt", preemptFunc(podLister))
+	if config.Bind {
+		scheduler.POST("/api/v1/k8sextension/bind", bindFunc(kubeClient))
+	}
+	if config.NodeWatch.Enabled {
+		interval := time.Duration(config.NodeWatch.IntervalSeconds) * time.Second
+		reconciler = NewReconciler()
+		informer := kubeclient.NewPollingNodeInformer(kubeClient, interval)
+		informer.AddEventHandler(reconciler)
+		go informer.Run(make(chan struct{}))
+		go reconciler.Run(interval, make(chan struct{}))
+		if config.Publish.Enabled {
+			go NewPublisher(kubeClient, config.Publish).Run(reconciler.Nodes, make(chan struct{}))
+		}
+	}
+	readRoutes := ls.admin.engine.Group("/api/v1", ls.admin.auth.requireRead)
+	readRoutes.GET("/reconcile", reconcileFunc)
+	readRoutes.GET("/placements", placementsFunc)
+	readRoutes.GET("/metrics", metricsFunc)
+	readRoutes.GET("/metrics/:nodeid", metricFunc)
+	readRoutes.GET("/policy", policyFunc)
+	readRoutes.GET("/explain/:nodeid", explainFunc)
+	readRoutes.POST("/explain", explainArgsFunc)
+	readRoutes.GET("/processors", listProcessorsFunc)
+	readRoutes.GET("/processors/:name", getProcessorFunc)
+	adminRoutes := ls.admin.engine.Group("/api/v1", ls.admin.auth.requireAdmin)
+	adminRoutes.PUT("/processors", updateProcessorsFunc)
+	adminRoutes.PUT("/processors/:name", updateProcessorFunc)
+	// 旧接口，使用数字 id 表示 processor，保留以兼容
+	adminRoutes.PUT("/processor/:id/:weight", func(c *gin.Context) {
 		processorID := c.Param("id")
 		newWeight := c.Param("weight")
 
 		w, err := strconv.Atoi(newWeight)
 		id, err2 := strconv.Atoi(processorID)
-		_, ok := processor.ProcessorMap[processor.ProcessorType(id)]
-		if err != nil || err2 != nil || w < 0 || w > math.MaxInt32 || !ok {
+		_, ok := processor.Current().Processors[processor.ProcessorType(id)]
+		if err != nil || err2 != nil || w < 0 || w > int(processor.MaxExtraWeight) || !ok {
 			c.Status(http.StatusBadRequest)
 			return
 		}
-		processor.ProcessorMap[processor.ProcessorType(id)].ExtraWeight(int32(w))
+		weight := int32(w)
+		applyProcessorUpdates(c, map[string]processor.SettingsUpdate{
+			processor.ProcessorType(id).String(): {Weight: &weight},
+		})
 	})
 	go func() {
 		for metric := range ch {
 			processdata(metric)
 		}
 	}()
-	if err := r.Run(":8080"); err != nil {
+	if config.NodeExporter.Enabled {
+		// 不依赖 agent，直接从 node_exporter 抓取数据
+		client := &http.Client{Timeout: time.Duration(config.NodeExporter.TimeoutSeconds) * time.Second}
+		scraper := nodeexporter.NewScraper(client, time.Duration(config.NodeExporter.IntervalSeconds)*time.Second, func() []nodeexporter.Target {
+			return exporterTargets(config.NodeExporter)
+		})
+		go scraper.Run(make(chan struct{}), ch)
+	}
+	if err := ls.serve(); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"systeminfoagent/kubeclient"
+	"systeminfoagent/policy"
+	"systeminfoagent/processor"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	schedulerapi "k8s.io/kube-scheduler/extender/v1"
+)
+
+var preemptTimeout = time.Second * 5
+
+// preempt 去掉驱逐了 victims 之后仍然过载的节点
+// nodeCacheCapable 时 scheduler 只给出 victims 的 UID，通过 pods 查询节点上的 pod 计算释放的资源，
+// pods 为 nil 或者查询失败时无法计算，这些节点原样返回
+func preempt(pods kubeclient.PodLister, args schedulerapi.ExtenderPreemptionArgs) *schedulerapi.ExtenderPreemptionResult {
+	result := &schedulerapi.ExtenderPreemptionResult{
+		NodeNameToMetaVictims: map[string]*schedulerapi.MetaVictims{},
+	}
+	config := masterConfig.Policy.Select(args.Pod, processor.Current())
+	pod := processor.NewPodRequest(args.Pod)
+	overloaded := func(node string, freed processor.PodRequest) bool {
+		latestMetric, ok := getNodeLatestMetric(node)
+		if !ok {
+			return false
+		}
+		overloaded, reason := processor.Overloaded(&latestMetric, config, pod, freed)
+		if overloaded {
+			log.Printf("[info] preempt for pod %s: skip node %s: %s", policy.PodName(args.Pod), node, reason)
+		}
+		return overloaded
+	}
+	for node, victims := range args.NodeNameToVictims {
+		// 请求体中可以是 null，与 metaVictimsRequest 一样跳过
+		if victims == nil {
+			continue
+		}
+		var freed processor.PodRequest
+		metaVictims := &schedulerapi.MetaVictims{NumPDBViolations: victims.NumPDBViolations}
+		for _, victim := range victims.Pods {
+			freed.Add(processor.NewPodRequest(victim))
+			metaVictims.Pods = append(metaVictims.Pods, &schedulerapi.MetaPod{UID: string(victim.UID)})
+		}
+		if !overloaded(node, freed) {
+			result.NodeNameToMetaVictims[node] = metaVictims
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), preemptTimeout)
+	defer cancel()
+	for node, metaVictims := range args.NodeNameToMetaVictims {
+		if _, ok := args.NodeNameToVictims[node]; ok {
+			continue
+		}
+		freed, err := metaVictimsRequest(ctx, pods, node, metaVictims)
+		if err != nil {
+			log.Printf("[warn] preempt for pod %s: node %s is not filtered: %v", policy.PodName(args.Pod), node, err)
+			result.NodeNameToMetaVictims[node] = metaVictims
+			continue
+		}
+		if !overloaded(node, freed) {
+			result.NodeNameToMetaVictims[node] = metaVictims
+		}
+	}
+	log.Printf("[audit] preempt for pod %s with processor config version %d: %d of %d nodes left",
+		policy.PodName(args.Pod), config.Version, len(result.NodeNameToMetaVictims), len(args.NodeNameToVictims)+len(args.NodeNameToMetaVictims))
+	return result
+}
+
+// metaVictimsRequest 根据 UID 找到节点上的 victims，返回驱逐它们释放的资源
+// 已经不在节点上的 victim 不计入，它释放的资源已经体现在节点最新的数据中
+func metaVictimsRequest(ctx context.Context, pods kubeclient.PodLister, node string, victims *schedulerapi.MetaVictims) (processor.PodRequest, error) {
+	var freed processor.PodRequest
+	if victims == nil || len(victims.Pods) == 0 {
+		return freed, nil
+	}
+	if pods == nil {
+		return freed, fmt.Errorf("kubernetes client is not configured, cannot resolve victims")
+	}
+	list, err := pods.ListNodePods(ctx, node)
+	if err != nil {
+		return freed, fmt.Errorf("list pods: %v", err)
+	}
+	uids := make(map[string]bool, len(victims.Pods))
+	for _, victim := range victims.Pods {
+		uids[victim.UID] = true
+	}
+	for i := range list {
+		if uids[string(list[i].UID)] {
+			freed.Add(processor.NewPodRequest(&list[i]))
+		}
+	}
+	return freed, nil
+}
+
+// preemptFunc POST /api/v1/k8sextension/preempt
+func preemptFunc(pods kubeclient.PodLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var args schedulerapi.ExtenderPreemptionArgs
+		if err := c.ShouldBindJSON(&args); err != nil {
+			log.Printf("[err] json decode err:%v", err)
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.JSON(http.StatusOK, preempt(pods, args))
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"systeminfoagent/kubeclient"
+	"systeminfoagent/model"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	schedulerapi "k8s.io/kube-scheduler/extender/v1"
+)
+
+const gib = 1 << 30
+
+func memoryPod(uid, node string, memory string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: uid, UID: types.UID(uid)},
+		Spec: v1.PodSpec{
+			NodeName: node,
+			Containers: []v1.Container{{
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse(memory)}},
+			}},
+		},
+	}
+}
+
+// addMemoryMetric 为 node 添加一条只有内存数据的记录，free 和 available 相同
+func addMemoryMetric(t *testing.T, node string, total, free uint64) {
+	t.Helper()
+	processdata(&model.NodeMetric{
+		NodeInfo:  model.NodeInfo{ID: node},
+		Timestamp: time.Now(),
+		Memory:    model.Memory{Valid: true, Total: total, Free: free, Available: free},
+	})
+	t.Cleanup(func() { deleteRecord(node) })
+}
+
+func metaVictims(uids ...string) *schedulerapi.MetaVictims {
+	res := &schedulerapi.MetaVictims{}
+	for _, uid := range uids {
+		res.Pods = append(res.Pods, &schedulerapi.MetaPod{UID: uid})
+	}
+	return res
+}
+
+func TestPreemptVictims(t *testing.T) {
+	addMemoryMetric(t, "node-1", 10*gib, 1*gib)
+	addMemoryMetric(t, "node-2", 10*gib, 1*gib)
+	args := schedulerapi.ExtenderPreemptionArgs{
+		Pod: memoryPod("pending", "", "1Gi"),
+		NodeNameToVictims: map[string]*schedulerapi.Victims{
+			"node-1": {Pods: []*v1.Pod{memoryPod("big", "node-1", "2Gi")}, NumPDBViolations: 1},
+			"node-2": {Pods: []*v1.Pod{memoryPod("small", "node-2", "100Mi")}},
+		},
+	}
+	res := preempt(nil, args)
+	if len(res.NodeNameToMetaVictims) != 1 {
+		t.Fatalf("got nodes %v, want only node-1", res.NodeNameToMetaVictims)
+	}
+	victims := res.NodeNameToMetaVictims["node-1"]
+	if victims == nil || victims.NumPDBViolations != 1 || len(victims.Pods) != 1 || victims.Pods[0].UID != "big" {
+		t.Errorf("unexpected victims %+v", victims)
+	}
+}
+
+func TestPreemptNilVictims(t *testing.T) {
+	addMemoryMetric(t, "node-1", 10*gib, 1*gib)
+	var args schedulerapi.ExtenderPreemptionArgs
+	if err := json.Unmarshal([]byte(`{"Pod": {}, "NodeNameToVictims": {"node-1": null}}`), &args); err != nil {
+		t.Fatal(err)
+	}
+	res := preempt(nil, args)
+	if len(res.NodeNameToMetaVictims) != 0 {
+		t.Errorf("got nodes %v, want none", res.NodeNameToMetaVictims)
+	}
+}
+
+func TestPreemptMetaVictims(t *testing.T) {
+	addMemoryMetric(t, "node-1", 10*gib, 1*gib)
+	addMemoryMetric(t, "node-2", 10*gib, 1*gib)
+	client := kubeclient.NewFakeClient()
+	client.Pods = []v1.Pod{
+		*memoryPod("big", "node-1", "2Gi"),
+		*memoryPod("small", "node-2", "100Mi"),
+		// 其他节点上 UID 相同的 pod 不计入
+		*memoryPod("elsewhere", "node-3", "8Gi"),
+	}
+	args := schedulerapi.ExtenderPreemptionArgs{
+		Pod: memoryPod("pending", "", "1Gi"),
+		NodeNameToMetaVictims: map[string]*schedulerapi.MetaVictims{
+			"node-1": metaVictims("big"),
+			"node-2": metaVictims("small", "elsewhere"),
+			// 没有数据的节点不过滤
+			"node-4": metaVictims("unknown"),
+		},
+	}
+	res := preempt(client, args)
+	if _, ok := res.NodeNameToMetaVictims["node-1"]; !ok {
+		t.Error("node-1 should be kept")
+	}
+	if _, ok := res.NodeNameToMetaVictims["node-2"]; ok {
+		t.Error("node-2 should be filtered out")
+	}
+	if _, ok := res.NodeNameToMetaVictims["node-4"]; !ok {
+		t.Error("node-4 should be kept")
+	}
+}
+
+func TestPreemptMetaVictimsWithoutClient(t *testing.T) {
+	addMemoryMetric(t, "node-1", 10*gib, 1*gib)
+	args := schedulerapi.ExtenderPreemptionArgs{
+		Pod:                   memoryPod("pending", "", "1Gi"),
+		NodeNameToMetaVictims: map[string]*schedulerapi.MetaVictims{"node-1": metaVictims("small")},
+	}
+	if res := preempt(nil, args); res.NodeNameToMetaVictims["node-1"] == nil {
+		t.Error("node-1 should be returned unfiltered")
+	}
+}
+
+func cpuPod(uid, node string, cpu string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: uid, UID: types.UID(uid)},
+		Spec: v1.PodSpec{
+			NodeName: node,
+			Containers: []v1.Container{{
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}},
+			}},
+		},
+	}
+}
+
+// addCPUMetric 为 node 添加一条只有 CPU 数据的记录，idle 为空闲时间的百分比
+func addCPUMetric(t *testing.T, node string, idle uint64, cpus int) {
+	t.Helper()
+	processdata(&model.NodeMetric{
+		NodeInfo:  model.NodeInfo{ID: node},
+		Timestamp: time.Now(),
+		CPU: model.CPU{
+			Valid: true, User: 100 - idle, Idle: idle,
+			PerCore: &model.PerCore{Busy: make([]float64, cpus)},
+		},
+	})
+	t.Cleanup(func() { deleteRecord(node) })
+}
+
+func TestPreemptCPUSaturated(t *testing.T) {
+	addCPUMetric(t, "node-1", 0, 2)
+	addCPUMetric(t, "node-2", 0, 2)
+	args := schedulerapi.ExtenderPreemptionArgs{
+		Pod: cpuPod("pending", "", "500m"),
+		NodeNameToVictims: map[string]*schedulerapi.Victims{
+			"node-1": {Pods: []*v1.Pod{cpuPod("small", "node-1", "200m")}},
+			"node-2": {Pods: []*v1.Pod{cpuPod("big", "node-2", "1")}},
+		},
+	}
+	res := preempt(nil, args)
+	if _, ok := res.NodeNameToMetaVictims["node-1"]; ok {
+		t.Error("cpu saturated node-1 should be filtered out")
+	}
+	if _, ok := res.NodeNameToMetaVictims["node-2"]; !ok {
+		t.Error("node-2 should be kept")
+	}
+}
@@ -1,23 +1,63 @@
 package main
 
-import schedulerapi "k8s.io/kube-scheduler/extender/v1"
+import (
+	"log"
+	"systeminfoagent/policy"
+	"systeminfoagent/processor"
+
+	schedulerapi "k8s.io/kube-scheduler/extender/v1"
+)
 
 func prioritize(args schedulerapi.ExtenderArgs) *schedulerapi.HostPriorityList {
-	nodes := args.Nodes.Items
-	hostPriorityList := make(schedulerapi.HostPriorityList, len(nodes))
+	explanations := explain(args)
+	hostPriorityList := make(schedulerapi.HostPriorityList, len(explanations))
+	for i, explanation := range explanations {
+		hostPriorityList[i] = schedulerapi.HostPriority{
+			Host:  explanation.NodeID,
+			Score: int64(explanation.Score),
+		}
+	}
+	return &hostPriorityList
+}
+
+// explain 为 args 中的每个节点打分，没有数据的节点得分为 0
+func explain(args schedulerapi.ExtenderArgs) []*processor.Explanation {
+	nodes := nodeNames(args)
+	explanations := make([]*processor.Explanation, len(nodes))
+	// 同一次调度请求中的所有节点都使用同一份配置
+	config := masterConfig.Policy.Select(args.Pod, processor.Current())
+	pod := processor.NewPodRequest(args.Pod)
+	log.Printf("[audit] prioritize %d nodes for pod %s with processor config version %d, profile %q (rule %q), request %+v",
+		len(nodes), policy.PodName(args.Pod), config.Version, config.Profile, config.Rule, pod)
 	for i, node := range nodes {
-		record, ok, _ := getRecord(node.Name)
-		var score float64
-		if ok && len(record.Metrics) > 0 {
-			latestMetric := record.Metrics[len(record.Metrics)-1]
-			score, _ = metricProcessor.Score(&latestMetric)
+		if latestMetric, ok := getNodeLatestMetric(node); ok {
+			explanations[i] = metricProcessor.Explain(&latestMetric, config, pod)
 		} else {
-			score = 0.0
+			explanations[i] = &processor.Explanation{
+				NodeID:        node,
+				ConfigVersion: config.Version,
+				Scoring:       config.Scoring,
+				Profile:       config.Profile,
+				Rule:          config.Rule,
+				Pod:           pod,
+			}
 		}
-		hostPriorityList[i] = schedulerapi.HostPriority{
-			Host:  node.Name,
-			Score: int64(score),
+		explanations[i].NodeID = node
+	}
+	return explanations
+}
+
+// nodeNames nodeCacheCapable 为 true 时 scheduler 只发送节点名
+func nodeNames(args schedulerapi.ExtenderArgs) []string {
+	if args.Nodes != nil {
+		res := make([]string, len(args.Nodes.Items))
+		for i, node := range args.Nodes.Items {
+			res[i] = node.Name
 		}
+		return res
 	}
-	return &hostPriorityList
+	if args.NodeNames != nil {
+		return *args.NodeNames
+	}
+	return nil
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"systeminfoagent/processor"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listProcessorsFunc GET /api/v1/processors
+func listProcessorsFunc(c *gin.Context) {
+	c.JSON(http.StatusOK, processor.Current().Status())
+}
+
+// getProcessorFunc GET /api/v1/processors/:name
+func getProcessorFunc(c *gin.Context) {
+	name := c.Param("name")
+	for _, status := range processor.Current().Status().Processors {
+		if status.Name == name {
+			c.JSON(http.StatusOK, status)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "unknown processor " + name})
+}
+
+// updateProcessorsFunc PUT /api/v1/processors
+// body: {"cpu": {"weight": 150}, "network": {"enabled": false}}，全部修改要么同时生效要么都不生效
+func updateProcessorsFunc(c *gin.Context) {
+	var updates map[string]processor.SettingsUpdate
+	if err := c.BindJSON(&updates); err != nil {
+		log.Println("[err] parse json:", err)
+		return
+	}
+	applyProcessorUpdates(c, updates)
+}
+
+// updateProcessorFunc PUT /api/v1/processors/:name
+// body: {"weight": 150, "enabled": true}
+func updateProcessorFunc(c *gin.Context) {
+	var update processor.SettingsUpdate
+	if err := c.BindJSON(&update); err != nil {
+		log.Println("[err] parse json:", err)
+		return
+	}
+	applyProcessorUpdates(c, map[string]processor.SettingsUpdate{c.Param("name"): update})
+}
+
+func applyProcessorUpdates(c *gin.Context, updates map[string]processor.SettingsUpdate) {
+	config, err := processor.UpdateSettings(updates)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[info] processor settings updated to version %d: %+v", config.Version, updates)
+	c.JSON(http.StatusOK, config.Status())
+}
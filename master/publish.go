@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"systeminfoagent/kubeclient"
+	"systeminfoagent/model"
+	"systeminfoagent/processor"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	annotationPrefix = "systeminfoagent.io/"
+	// conditionHighDiskUsage 磁盘使用率超过阈值时为 True
+	conditionHighDiskUsage v1.NodeConditionType = "HighDiskUsage"
+)
+
+// PublishConfig 将节点的分数和主要指标写回 Node
+type PublishConfig struct {
+	Enabled bool `json:"enabled"`
+	// IntervalSeconds 同一个节点两次写入之间的最小间隔
+	IntervalSeconds int `json:"interval_seconds"`
+	// ScoreDelta 分数变化超过该值才会写入 annotation
+	ScoreDelta float64 `json:"score_delta"`
+	// HighDiskUsagePercent 磁盘使用率超过该值时设置 HighDiskUsage condition，为 0 时不设置 condition
+	HighDiskUsagePercent float64 `json:"high_disk_usage_percent"`
+}
+
+// published 某个节点上一次写入的内容
+type published struct {
+	at       time.Time
+	score    float64
+	highDisk bool
+}
+
+// Publisher 定期把节点的状态写回 Node
+type Publisher struct {
+	client kubeclient.NodePatcher
+	config PublishConfig
+	last   map[string]published
+}
+
+func NewPublisher(client kubeclient.NodePatcher, config PublishConfig) *Publisher {
+	return &Publisher{
+		client: client,
+		config: config,
+		last:   map[string]published{},
+	}
+}
+
+// Run 阻塞运行，直到 stop 被关闭
+func (p *Publisher) Run(nodes func() []*v1.Node, stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(p.config.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			cached := map[string]bool{}
+			for _, node := range nodes() {
+				cached[node.Name] = true
+				if latestMetric, ok := getNodeLatestMetric(node.Name); ok {
+					p.Publish(node.Name, &latestMetric, time.Now())
+				}
+			}
+			p.prune(cached)
+		}
+	}
+}
+
+// prune 删除已经不在缓存中的节点上一次写入的记录
+func (p *Publisher) prune(cached map[string]bool) {
+	for name := range p.last {
+		if !cached[name] {
+			delete(p.last, name)
+		}
+	}
+}
+
+// dropped 节点已经被删除时不再记录，返回是否为这种情况
+func (p *Publisher) dropped(nodeName string, err error) bool {
+	if !kubeclient.IsNotFound(err) {
+		return false
+	}
+	log.Printf("[info] skip publishing node %s: %v", nodeName, err)
+	delete(p.last, nodeName)
+	return true
+}
+
+// Publish 在距离上次写入超过间隔并且内容有明显变化时写入 node
+func (p *Publisher) Publish(nodeName string, metric *model.NodeFullMetric, now time.Time) {
+	last, ok := p.last[nodeName]
+	// Run 的周期与间隔相同，留出 1/10 的余量，避免 ticker 的抖动导致每隔一次才写入
+	interval := time.Duration(p.config.IntervalSeconds) * time.Second
+	if ok && now.Sub(last.at) < interval-interval/10 {
+		return
+	}
+	config := processor.Current()
+	score, _ := metricProcessor.Score(metric, config, processor.PodRequest{})
+	diskUsage := percent(metric.RawMetric.Disk.Used, metric.RawMetric.Disk.Size)
+	current := published{
+		at:       now,
+		score:    score,
+		highDisk: p.config.HighDiskUsagePercent > 0 && diskUsage >= p.config.HighDiskUsagePercent,
+	}
+	scoreChanged := !ok || math.Abs(current.score-last.score) >= p.config.ScoreDelta
+	diskChanged := p.config.HighDiskUsagePercent > 0 && (!ok || current.highDisk != last.highDisk)
+	if !scoreChanged && !diskChanged {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if scoreChanged {
+		annotations := summaryAnnotations(metric, config, score, diskUsage, now)
+		// patch 不带 resourceVersion，apiserver 不会返回冲突，失败时等下一轮重新写入
+		if err := p.client.PatchNodeAnnotations(ctx, nodeName, annotations); err != nil {
+			if !p.dropped(nodeName, err) {
+				log.Printf("[err] publish annotations of node %s: %v", nodeName, err)
+			}
+			return
+		}
+	}
+	if diskChanged {
+		condition := highDiskCondition(current.highDisk, diskUsage, p.config.HighDiskUsagePercent, now)
+		if err := p.client.SetNodeCondition(ctx, nodeName, condition); err != nil {
+			if !p.dropped(nodeName, err) {
+				log.Printf("[err] publish condition of node %s: %v", nodeName, err)
+			}
+			return
+		}
+	}
+	if !scoreChanged {
+		// 分数的变化是相对于上一次写入的值计算的
+		current.score = last.score
+	}
+	p.last[nodeName] = current
+}
+
+// summaryAnnotations config 为计算 score 时使用的配置
+func summaryAnnotations(metric *model.NodeFullMetric, config *processor.Config, score, diskUsage float64, now time.Time) map[string]string {
+	raw := metric.RawMetric
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	return map[string]string{
+		annotationPrefix + "score":                       format(score),
+		annotationPrefix + "cpu-idle-percent":            format(percent(raw.CPU.Idle, raw.CPU.Total())),
+		annotationPrefix + "memory-free-percent":         format(percent(raw.Memory.Free, raw.Memory.Total)),
+		annotationPrefix + "disk-usage-percent":          format(diskUsage),
+		annotationPrefix + "network-rx-bytes-per-second": strconv.FormatUint(raw.Network.RxBytes, 10),
+		annotationPrefix + "network-tx-bytes-per-second": strconv.FormatUint(raw.Network.TxBytes, 10),
+		annotationPrefix + "config-version":              strconv.FormatUint(config.Version, 10),
+		annotationPrefix + "updated-at":                  now.UTC().Format(time.RFC3339),
+	}
+}
+
+func highDiskCondition(high bool, diskUsage, threshold float64, now time.Time) v1.NodeCondition {
+	condition := v1.NodeCondition{
+		Type:               conditionHighDiskUsage,
+		Status:             v1.ConditionFalse,
+		LastHeartbeatTime:  metav1.NewTime(now),
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "DiskUsageNormal",
+		Message:            fmt.Sprintf("disk usage %.2f%% is below %.2f%%", diskUsage, threshold),
+	}
+	if high {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = "DiskUsageHigh"
+		condition.Message = fmt.Sprintf("disk usage %.2f%% reaches %.2f%%", diskUsage, threshold)
+	}
+	return condition
+}
+
+func percent(a, b uint64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b) * 100.0
+}
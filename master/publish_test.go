@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"systeminfoagent/kubeclient"
+	"systeminfoagent/model"
+	"systeminfoagent/processor"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func publishMetric(idle, diskUsed uint64) *model.NodeFullMetric {
+	return &model.NodeFullMetric{RawMetric: model.NodeMetric{
+		CPU:    model.CPU{Valid: true, User: 100 - idle, Idle: idle},
+		Memory: model.Memory{Valid: true, Total: 100, Free: 50, Available: 50},
+		Disk:   model.Disk{Valid: true, Size: 100 << 30, Used: diskUsed << 30, Free: (100 - diskUsed) << 30},
+	}}
+}
+
+func newTestPublisher() (*Publisher, *kubeclient.FakeClient) {
+	client := kubeclient.NewFakeClient()
+	client.AddNode(testNode("node-1", "", ""))
+	return NewPublisher(client, PublishConfig{Enabled: true, IntervalSeconds: 60, ScoreDelta: 5, HighDiskUsagePercent: 90}), client
+}
+
+func nodeCondition(node v1.Node, t v1.NodeConditionType) *v1.NodeCondition {
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == t {
+			return &node.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func TestPublish(t *testing.T) {
+	p, client := newTestPublisher()
+	now := time.Now()
+	p.Publish("node-1", publishMetric(80, 50), now)
+	node := client.Nodes["node-1"]
+	for _, key := range []string{"score", "cpu-idle-percent", "disk-usage-percent", "config-version", "updated-at"} {
+		if _, ok := node.Annotations[annotationPrefix+key]; !ok {
+			t.Errorf("annotation %s is missing", key)
+		}
+	}
+	if v := node.Annotations[annotationPrefix+"cpu-idle-percent"]; v != "80.00" {
+		t.Errorf("cpu-idle-percent = %s", v)
+	}
+	if c := nodeCondition(node, conditionHighDiskUsage); c == nil || c.Status != v1.ConditionFalse {
+		t.Errorf("unexpected condition %+v", c)
+	}
+	updatedAt := node.Annotations[annotationPrefix+"updated-at"]
+
+	// 间隔内不写入
+	p.Publish("node-1", publishMetric(10, 95), now.Add(30*time.Second))
+	if node := client.Nodes["node-1"]; node.Annotations[annotationPrefix+"updated-at"] != updatedAt || nodeCondition(node, conditionHighDiskUsage).Status != v1.ConditionFalse {
+		t.Error("published within the interval")
+	}
+
+	// 分数变化不明显时不写入 annotation
+	p.Publish("node-1", publishMetric(79, 50), now.Add(2*time.Minute))
+	if client.Nodes["node-1"].Annotations[annotationPrefix+"updated-at"] != updatedAt {
+		t.Error("published a small score change")
+	}
+
+	// 磁盘超过阈值时只修改 condition
+	p.Publish("node-1", publishMetric(79, 95), now.Add(4*time.Minute))
+	node = client.Nodes["node-1"]
+	if c := nodeCondition(node, conditionHighDiskUsage); c == nil || c.Status != v1.ConditionTrue {
+		t.Errorf("unexpected condition %+v", c)
+	}
+}
+
+func TestPublishTickerJitter(t *testing.T) {
+	p, client := newTestPublisher()
+	now := time.Now()
+	p.Publish("node-1", publishMetric(80, 50), now)
+	// ticker 比间隔稍早触发时仍然写入
+	later := now.Add(59500 * time.Millisecond)
+	p.Publish("node-1", publishMetric(10, 50), later)
+	if got := client.Nodes["node-1"].Annotations[annotationPrefix+"updated-at"]; got != later.UTC().Format(time.RFC3339) {
+		t.Errorf("updated-at = %s, want %s", got, later.UTC().Format(time.RFC3339))
+	}
+}
+
+func TestPublishConfigVersion(t *testing.T) {
+	current := processor.Current()
+	annotations := summaryAnnotations(publishMetric(80, 50), &processor.Config{Version: current.Version + 10}, 50, 50, time.Now())
+	if v := annotations[annotationPrefix+"config-version"]; v != strconv.FormatUint(current.Version+10, 10) {
+		t.Errorf("config-version = %s, want the version of the scoring config", v)
+	}
+}
+
+func TestPublishPrune(t *testing.T) {
+	p, client := newTestPublisher()
+	client.AddNode(testNode("node-2", "", ""))
+	now := time.Now()
+	p.Publish("node-1", publishMetric(80, 50), now)
+	p.Publish("node-2", publishMetric(80, 50), now)
+	p.prune(map[string]bool{"node-2": true})
+	if _, ok := p.last["node-1"]; ok {
+		t.Error("removed node-1 was not pruned")
+	}
+	if _, ok := p.last["node-2"]; !ok {
+		t.Error("node-2 was pruned")
+	}
+	// 已经被删除的节点返回 404，不再记录
+	client.DeleteNode("node-2")
+	p.Publish("node-2", publishMetric(10, 50), now.Add(2*time.Minute))
+	if _, ok := p.last["node-2"]; ok {
+		t.Error("deleted node-2 is still recorded")
+	}
+}
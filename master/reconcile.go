@@ -0,0 +1,260 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"systeminfoagent/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Reconciler 将 agent 上报时使用的 ID 与 k8s 中的节点名对应起来
+// 依次尝试：节点名、kubernetes.io/hostname label、节点地址，以及 agent 的来源地址与节点 InternalIP
+type Reconciler struct {
+	lock  sync.RWMutex
+	nodes map[string]*v1.Node
+	// nodeToAgent 节点名 -> agent ID
+	nodeToAgent map[string]string
+	status      ReconcileStatus
+}
+
+// ReconcileStatus 对应关系以及无法对应的 agent 和节点
+type ReconcileStatus struct {
+	NodeToAgent       map[string]string `json:"node_to_agent"`
+	UnmatchedAgents   []string          `json:"unmatched_agents"`
+	NodesWithoutAgent []string          `json:"nodes_without_agent"`
+}
+
+func NewReconciler() *Reconciler {
+	return &Reconciler{
+		nodes:       map[string]*v1.Node{},
+		nodeToAgent: map[string]string{},
+	}
+}
+
+// OnAdd 只记录节点，对应关系在 OnSync 中统一计算，
+// 启动时第一次 list 到的所有节点只需要计算一次
+func (r *Reconciler) OnAdd(node *v1.Node) {
+	r.lock.Lock()
+	r.nodes[node.Name] = node
+	r.lock.Unlock()
+}
+
+func (r *Reconciler) OnUpdate(_, node *v1.Node) {
+	r.OnAdd(node)
+}
+
+// OnDelete 节点被删除时同时删除对应 agent 的记录
+func (r *Reconciler) OnDelete(node *v1.Node) {
+	r.lock.Lock()
+	delete(r.nodes, node.Name)
+	agentID, ok := r.nodeToAgent[node.Name]
+	r.lock.Unlock()
+	if ok {
+		log.Printf("[info] node %s deleted, remove records of agent %s", node.Name, agentID)
+		deleteRecord(agentID)
+	}
+}
+
+// OnSync 一次 list 产生的事件处理完之后重新计算对应关系
+func (r *Reconciler) OnSync() {
+	r.Reconcile()
+}
+
+// Run 定期重新计算对应关系，新加入的 agent 可以尽快被识别
+func (r *Reconciler) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.Reconcile()
+		}
+	}
+}
+
+// Reconcile 根据当前的节点和 agent 重新计算对应关系
+func (r *Reconciler) Reconcile() {
+	agents := getAgentAddresses()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	agentIDs := sortedKeys(agents)
+	names := sortedNodeNames(r.nodes)
+	nodeToAgent := map[string]string{}
+	matchedAgents := map[string]bool{}
+	// 按照匹配方式的优先级依次匹配，已经匹配上的节点和 agent 不再参与后面的匹配
+	for _, match := range []func(node *v1.Node, agentID, address string) bool{
+		matchName, matchHostname, matchNodeAddress, matchInternalIP,
+	} {
+		for _, agentID := range agentIDs {
+			if matchedAgents[agentID] {
+				continue
+			}
+			for _, name := range names {
+				if _, ok := nodeToAgent[name]; ok {
+					continue
+				}
+				if match(r.nodes[name], agentID, agents[agentID]) {
+					nodeToAgent[name] = agentID
+					matchedAgents[agentID] = true
+					break
+				}
+			}
+		}
+	}
+
+	status := ReconcileStatus{NodeToAgent: nodeToAgent, UnmatchedAgents: []string{}, NodesWithoutAgent: []string{}}
+	for _, agentID := range agentIDs {
+		if !matchedAgents[agentID] {
+			status.UnmatchedAgents = append(status.UnmatchedAgents, agentID)
+		}
+	}
+	for _, name := range names {
+		if _, ok := nodeToAgent[name]; !ok {
+			status.NodesWithoutAgent = append(status.NodesWithoutAgent, name)
+		}
+	}
+	if !equalStrings(status.UnmatchedAgents, r.status.UnmatchedAgents) && len(status.UnmatchedAgents) > 0 {
+		log.Printf("[warn] agents not matched to any node: %v", status.UnmatchedAgents)
+	}
+	if !equalStrings(status.NodesWithoutAgent, r.status.NodesWithoutAgent) && len(status.NodesWithoutAgent) > 0 {
+		log.Printf("[warn] nodes without agent: %v", status.NodesWithoutAgent)
+	}
+	r.nodeToAgent = nodeToAgent
+	r.status = status
+}
+
+// AgentID 返回节点对应的 agent ID，没有对应关系时返回节点名本身
+func (r *Reconciler) AgentID(nodeName string) string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if agentID, ok := r.nodeToAgent[nodeName]; ok {
+		return agentID
+	}
+	return nodeName
+}
+
+// Nodes 返回当前已知的所有节点
+func (r *Reconciler) Nodes() []*v1.Node {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	res := make([]*v1.Node, 0, len(r.nodes))
+	for _, name := range sortedNodeNames(r.nodes) {
+		res = append(res, r.nodes[name])
+	}
+	return res
+}
+
+func (r *Reconciler) Status() ReconcileStatus {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.status
+}
+
+func matchName(node *v1.Node, agentID, _ string) bool {
+	return node.Name == agentID
+}
+
+func matchHostname(node *v1.Node, agentID, _ string) bool {
+	return node.Labels[v1.LabelHostname] == agentID
+}
+
+func matchNodeAddress(node *v1.Node, agentID, _ string) bool {
+	for _, addr := range node.Status.Addresses {
+		if addr.Address == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+func matchInternalIP(node *v1.Node, _, address string) bool {
+	if address == "" {
+		return false
+	}
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == v1.NodeInternalIP && addr.Address == address {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func sortedNodeNames(m map[string]*v1.Node) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// reconciler 没有开启节点监听时为 nil
+var reconciler *Reconciler
+
+// getNodeLatestMetric 按照 k8s 节点名查询最新的数据
+func getNodeLatestMetric(nodeName string) (model.NodeFullMetric, bool) {
+	agentID := nodeName
+	if reconciler != nil {
+		agentID = reconciler.AgentID(nodeName)
+	}
+	return getLatestMetric(agentID)
+}
+
+// getNodeLatestMetrics 返回所有节点最新的数据，按照 k8s 节点名索引
+// 没有对应到节点的 agent 使用 agent ID，与 getNodeLatestMetric 的查找方式一致
+func getNodeLatestMetrics() map[string]model.NodeFullMetric {
+	metrics := getLatestMetrics()
+	if reconciler == nil {
+		return metrics
+	}
+	res := make(map[string]model.NodeFullMetric, len(metrics))
+	for nodeName, agentID := range reconciler.Status().NodeToAgent {
+		if metric, ok := metrics[agentID]; ok {
+			res[nodeName] = metric
+			delete(metrics, agentID)
+		}
+	}
+	for agentID, metric := range metrics {
+		if _, ok := res[agentID]; !ok {
+			res[agentID] = metric
+		}
+	}
+	return res
+}
+
+// reconcileFunc GET /api/v1/reconcile
+func reconcileFunc(c *gin.Context) {
+	if reconciler == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node watch is not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, reconciler.Status())
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"systeminfoagent/kubeclient"
+	"systeminfoagent/model"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// countingReconciler 记录 OnSync 被调用的次数
+type countingReconciler struct {
+	*Reconciler
+	syncs int
+}
+
+func (c *countingReconciler) OnSync() {
+	c.syncs++
+	c.Reconciler.OnSync()
+}
+
+func testNode(name, hostname, internalIP string) v1.Node {
+	node := v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}}}
+	if hostname != "" {
+		node.Labels[v1.LabelHostname] = hostname
+	}
+	if internalIP != "" {
+		node.Status.Addresses = []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: internalIP}}
+	}
+	return node
+}
+
+// addAgent 模拟 agent 从 address 上报了一次数据
+func addAgent(t *testing.T, id, address string) {
+	t.Helper()
+	processdata(&model.NodeMetric{NodeInfo: model.NodeInfo{ID: id, Address: address}, Timestamp: time.Now()})
+	t.Cleanup(func() { deleteRecord(id) })
+}
+
+func TestReconcileMatches(t *testing.T) {
+	addAgent(t, "node-a", "10.0.0.1")
+	addAgent(t, "host-b", "10.0.0.2")
+	addAgent(t, "random-id", "10.0.0.3")
+	addAgent(t, "orphan", "10.0.0.9")
+	client := kubeclient.NewFakeClient()
+	client.AddNode(testNode("node-a", "", ""))
+	client.AddNode(testNode("node-b", "host-b", ""))
+	client.AddNode(testNode("node-c", "", "10.0.0.3"))
+	client.AddNode(testNode("node-d", "", ""))
+
+	r := &countingReconciler{Reconciler: NewReconciler()}
+	informer := kubeclient.NewPollingNodeInformer(client, time.Minute)
+	informer.AddEventHandler(r)
+	if err := informer.Resync(); err != nil {
+		t.Fatal(err)
+	}
+	// 第一次 list 到的所有节点只计算一次对应关系
+	if r.syncs != 1 {
+		t.Errorf("reconciled %d times after the initial list, want 1", r.syncs)
+	}
+	want := ReconcileStatus{
+		NodeToAgent:       map[string]string{"node-a": "node-a", "node-b": "host-b", "node-c": "random-id"},
+		UnmatchedAgents:   []string{"orphan"},
+		NodesWithoutAgent: []string{"node-d"},
+	}
+	if got := r.Status(); !reflect.DeepEqual(got, want) {
+		t.Errorf("status = %+v, want %+v", got, want)
+	}
+	if id := r.AgentID("node-c"); id != "random-id" {
+		t.Errorf("AgentID(node-c) = %q", id)
+	}
+	if id := r.AgentID("unknown"); id != "unknown" {
+		t.Errorf("AgentID(unknown) = %q", id)
+	}
+
+	// 没有变化时不重新计算
+	if err := informer.Resync(); err != nil {
+		t.Fatal(err)
+	}
+	if r.syncs != 1 {
+		t.Errorf("reconciled %d times without changes, want 1", r.syncs)
+	}
+
+	// 删除节点时同时删除对应 agent 的数据
+	client.DeleteNode("node-c")
+	if err := informer.Resync(); err != nil {
+		t.Fatal(err)
+	}
+	if r.syncs != 2 {
+		t.Errorf("reconciled %d times after a delete, want 2", r.syncs)
+	}
+	if _, ok := getLatestMetric("random-id"); ok {
+		t.Error("records of the deleted node's agent were not removed")
+	}
+	if _, ok := r.Status().NodeToAgent["node-c"]; ok {
+		t.Error("deleted node is still matched")
+	}
+}
+
+func TestReconcilePriority(t *testing.T) {
+	// node-x 的名称与 agent 相同，优先于 node-y 的 hostname label
+	addAgent(t, "node-x", "")
+	client := kubeclient.NewFakeClient()
+	client.AddNode(testNode("node-y", "node-x", ""))
+	client.AddNode(testNode("node-x", "", ""))
+	r := NewReconciler()
+	informer := kubeclient.NewPollingNodeInformer(client, time.Minute)
+	informer.AddEventHandler(r)
+	if err := informer.Resync(); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Status().NodeToAgent; !reflect.DeepEqual(got, map[string]string{"node-x": "node-x"}) {
+		t.Errorf("NodeToAgent = %v", got)
+	}
+}
+
+func TestNodeLatestMetrics(t *testing.T) {
+	addAgent(t, "host-b", "")
+	addAgent(t, "orphan", "")
+	client := kubeclient.NewFakeClient()
+	client.AddNode(testNode("node-b", "host-b", ""))
+	r := NewReconciler()
+	informer := kubeclient.NewPollingNodeInformer(client, time.Minute)
+	informer.AddEventHandler(r)
+	if err := informer.Resync(); err != nil {
+		t.Fatal(err)
+	}
+	reconciler = r
+	defer func() { reconciler = nil }()
+	metrics := getNodeLatestMetrics()
+	if len(metrics) != 2 || metrics["node-b"].NodeInfo.ID != "host-b" || metrics["orphan"].NodeInfo.ID != "orphan" {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+}
+
+func TestRemoteIPIgnoresForwardedHeaders(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/agenthealth/node-1", nil)
+	req.RemoteAddr = "10.0.0.1:34567"
+	// 伪造的地址不能用来匹配其他节点的 InternalIP
+	req.Header.Set("X-Forwarded-For", "10.0.0.2")
+	req.Header.Set("X-Real-IP", "10.0.0.3")
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	if got := remoteIP(c); got != "10.0.0.1" {
+		t.Errorf("remoteIP = %q, want 10.0.0.1", got)
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const extenderPathPrefix = "/api/v1/k8sextension"
+
+// ExtenderConfig scheduler 访问 master 的方式，用于生成和校验 scheduler 的配置
+type ExtenderConfig struct {
+	// URLPrefix 为空时根据 scheduler 使用的监听地址生成 http(s)://127.0.0.1:<port>/api/v1/k8sextension
+	URLPrefix        string `json:"url_prefix"`
+	Weight           int64  `json:"weight"`
+	TimeoutSeconds   int    `json:"timeout_seconds"`
+	NodeCacheCapable bool   `json:"node_cache_capable"`
+	Ignorable        bool   `json:"ignorable"`
+	// TLS scheduler 使用 https 访问 master 时的证书，路径为 scheduler 所在机器上的路径
+	TLS ExtenderTLSConfig `json:"tls"`
+}
+
+// ExtenderTLSConfig 生成 extender 的 tlsConfig
+// CAFile 用于校验 master 的证书，即签发 master 服务端证书的 CA，https 时必须设置，
+// 不会使用监听地址的 client_ca_file，它校验的是 scheduler 的客户端证书，不一定签发了 master 的证书；
+// CertFile 和 KeyFile 为 scheduler 的客户端证书，master 要求 scheduler 提供证书时必须设置；
+// ServerName 为 master 证书中的域名，urlPrefix 中的 127.0.0.1 不在证书的 SAN 中时需要设置
+type ExtenderTLSConfig struct {
+	CAFile     string `json:"ca_file"`
+	CertFile   string `json:"cert_file"`
+	KeyFile    string `json:"key_file"`
+	ServerName string `json:"server_name"`
+}
+
+// extenderStanza scheduler 配置中的 extender 一项里两种格式共用的字段，
+// httpTimeout 的格式不同，enableHTTPS 的字段名不同，见 policyExtender 和 kubeExtender
+type extenderStanza struct {
+	URLPrefix        string      `json:"urlPrefix" yaml:"urlPrefix"`
+	FilterVerb       string      `json:"filterVerb,omitempty" yaml:"filterVerb,omitempty"`
+	PrioritizeVerb   string      `json:"prioritizeVerb,omitempty" yaml:"prioritizeVerb,omitempty"`
+	PreemptVerb      string      `json:"preemptVerb,omitempty" yaml:"preemptVerb,omitempty"`
+	BindVerb         string      `json:"bindVerb,omitempty" yaml:"bindVerb,omitempty"`
+	Weight           int64       `json:"weight" yaml:"weight"`
+	HTTPTimeout      interface{} `json:"httpTimeout,omitempty" yaml:"httpTimeout,omitempty"`
+	NodeCacheCapable bool        `json:"nodeCacheCapable" yaml:"nodeCacheCapable"`
+	Ignorable        bool        `json:"ignorable" yaml:"ignorable"`
+	// TLSConfig 两种格式的字段名相同
+	TLSConfig *extenderTLSConfig `json:"tlsConfig,omitempty" yaml:"tlsConfig,omitempty"`
+}
+
+// extenderTLSConfig scheduler 访问 extender 使用的 TLS 配置，*Data 为 base64 编码的内容，只在校验时读取
+type extenderTLSConfig struct {
+	Insecure   bool   `json:"insecure,omitempty" yaml:"insecure,omitempty"`
+	ServerName string `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+	CertFile   string `json:"certFile,omitempty" yaml:"certFile,omitempty"`
+	KeyFile    string `json:"keyFile,omitempty" yaml:"keyFile,omitempty"`
+	CAFile     string `json:"caFile,omitempty" yaml:"caFile,omitempty"`
+	CertData   string `json:"certData,omitempty" yaml:"certData,omitempty"`
+	KeyData    string `json:"keyData,omitempty" yaml:"keyData,omitempty"`
+	CAData     string `json:"caData,omitempty" yaml:"caData,omitempty"`
+}
+
+func (t *extenderTLSConfig) hasCA() bool {
+	return t != nil && (t.CAFile != "" || t.CAData != "")
+}
+
+func (t *extenderTLSConfig) hasClientCert() bool {
+	return t != nil && (t.CertFile != "" && t.KeyFile != "" || t.CertData != "" && t.KeyData != "")
+}
+
+// policyExtender 旧的 Policy 中的 extender，字段名为 enableHttps
+type policyExtender struct {
+	extenderStanza `yaml:",inline"`
+	EnableHTTPS    bool `json:"enableHttps" yaml:"enableHttps"`
+}
+
+// kubeExtender KubeSchedulerConfiguration 中的 extender，字段名为 enableHTTPS
+type kubeExtender struct {
+	extenderStanza `yaml:",inline"`
+	EnableHTTPS    bool `json:"enableHTTPS" yaml:"enableHTTPS"`
+}
+
+type policyFile struct {
+	APIVersion string           `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string           `json:"kind" yaml:"kind"`
+	Extenders  []policyExtender `json:"extenders" yaml:"extenders"`
+}
+
+type kubeSchedulerConfigurationFile struct {
+	APIVersion string         `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string         `json:"kind" yaml:"kind"`
+	Extenders  []kubeExtender `json:"extenders" yaml:"extenders"`
+}
+
+func (c *Config) extenderURLPrefix() string {
+	if c.Extender.URLPrefix != "" {
+		return strings.TrimSuffix(c.Extender.URLPrefix, "/")
+	}
+	addr, scheme := c.Addr, "http://"
+	if c.Listeners.Scheduler.Addr != "" {
+		addr = c.Listeners.Scheduler.Addr
+	}
+	if tlsConfig, _ := c.Listeners.Scheduler.security(c); tlsConfig.enabled() {
+		scheme = "https://"
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		port = "8080"
+	}
+	return scheme + net.JoinHostPort("127.0.0.1", port) + extenderPathPrefix
+}
+
+// extenderTLSConfig 根据 master 的配置生成 tlsConfig，使用 http 时返回 nil
+// https 时必须配置 CA，master 要求 scheduler 的客户端证书时还必须配置证书
+func (c *Config) extenderTLSConfig() (*extenderTLSConfig, error) {
+	if !c.extenderHTTPS() {
+		return nil, nil
+	}
+	listenerTLS, _ := c.Listeners.Scheduler.security(c)
+	res := &extenderTLSConfig{
+		CAFile:     c.Extender.TLS.CAFile,
+		CertFile:   c.Extender.TLS.CertFile,
+		KeyFile:    c.Extender.TLS.KeyFile,
+		ServerName: c.Extender.TLS.ServerName,
+	}
+	if !res.hasCA() {
+		return nil, fmt.Errorf("extender.tls.ca_file is required when the scheduler listener uses https")
+	}
+	if listenerTLS.RequireSchedulerClientCert && !res.hasClientCert() {
+		return nil, fmt.Errorf("extender.tls.cert_file and extender.tls.key_file are required when tls.require_scheduler_client_cert is set")
+	}
+	return res, nil
+}
+
+// extenderStanza 根据 master 的配置生成 extender 一项，httpTimeout 和 tlsConfig 需要调用方填写
+func (c *Config) extenderStanza() extenderStanza {
+	stanza := extenderStanza{
+		URLPrefix:        c.extenderURLPrefix(),
+		PrioritizeVerb:   "prioritize",
+		PreemptVerb:      "preempt",
+		Weight:           c.Extender.Weight,
+		NodeCacheCapable: c.Extender.NodeCacheCapable,
+		Ignorable:        c.Extender.Ignorable,
+	}
+	if c.Bind {
+		stanza.BindVerb = "bind"
+	}
+	return stanza
+}
+
+// extenderHTTPS scheduler 是否需要使用 https 访问 master
+func (c *Config) extenderHTTPS() bool {
+	return strings.HasPrefix(c.extenderURLPrefix(), "https://")
+}
+
+// legacyPolicy 生成旧的 Policy 配置（--policy-config-file），httpTimeout 单位为纳秒
+func legacyPolicy(c *Config) ([]byte, error) {
+	stanza := c.extenderStanza()
+	tlsConfig, err := c.extenderTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	stanza.TLSConfig = tlsConfig
+	stanza.HTTPTimeout = int64(time.Duration(c.Extender.TimeoutSeconds) * time.Second)
+	return json.MarshalIndent(policyFile{
+		APIVersion: "v1",
+		Kind:       "Policy",
+		Extenders:  []policyExtender{{extenderStanza: stanza, EnableHTTPS: c.extenderHTTPS()}},
+	}, "", "  ")
+}
+
+// kubeSchedulerConfiguration 生成 KubeSchedulerConfiguration 中的 extenders 部分
+func kubeSchedulerConfiguration(c *Config) ([]byte, error) {
+	stanza := c.extenderStanza()
+	tlsConfig, err := c.extenderTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	stanza.TLSConfig = tlsConfig
+	stanza.HTTPTimeout = (time.Duration(c.Extender.TimeoutSeconds) * time.Second).String()
+	return yaml.Marshal(kubeSchedulerConfigurationFile{
+		APIVersion: "kubescheduler.config.k8s.io/v1beta1",
+		Kind:       "KubeSchedulerConfiguration",
+		Extenders:  []kubeExtender{{extenderStanza: stanza, EnableHTTPS: c.extenderHTTPS()}},
+	})
+}
+
+// parsedExtender 从两种格式中解析出来的 extender，httpsField 为该格式中 enableHTTPS 的字段名
+type parsedExtender struct {
+	extenderStanza
+	enableHTTPS bool
+	httpsField  string
+}
+
+// parseSchedulerConfig 按照 kind 解析 scheduler 配置中的 extenders
+// json 是 yaml 的子集，两种格式都可以用 yaml 解析
+func parseSchedulerConfig(data []byte) ([]parsedExtender, error) {
+	var header struct {
+		Kind string `yaml:"kind"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("parse scheduler config: %v", err)
+	}
+	var res []parsedExtender
+	switch header.Kind {
+	case "Policy":
+		var file policyFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("parse scheduler config: %v", err)
+		}
+		for _, e := range file.Extenders {
+			res = append(res, parsedExtender{e.extenderStanza, e.EnableHTTPS, "enableHttps"})
+		}
+	case "KubeSchedulerConfiguration":
+		var file kubeSchedulerConfigurationFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("parse scheduler config: %v", err)
+		}
+		for _, e := range file.Extenders {
+			res = append(res, parsedExtender{e.extenderStanza, e.EnableHTTPS, "enableHTTPS"})
+		}
+	default:
+		return nil, fmt.Errorf("parse scheduler config: unsupported kind %q", header.Kind)
+	}
+	return res, nil
+}
+
+// validateSchedulerConfig 检查 scheduler 配置中指向 master 的 extender 是否与 master 的能力一致
+// 支持 json 格式的 Policy 和 yaml 格式的 KubeSchedulerConfiguration，返回发现的所有问题
+func validateSchedulerConfig(c *Config, data []byte) ([]string, error) {
+	extenders, err := parseSchedulerConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	want := c.extenderStanza()
+	var problems []string
+	var stanza *parsedExtender
+	for i := range extenders {
+		if strings.TrimSuffix(extenders[i].URLPrefix, "/") == want.URLPrefix {
+			stanza = &extenders[i]
+			break
+		}
+	}
+	if stanza == nil {
+		return []string{fmt.Sprintf("no extender with urlPrefix %s", want.URLPrefix)}, nil
+	}
+	if stanza.FilterVerb != "" {
+		problems = append(problems, fmt.Sprintf("filterVerb %q is set but master does not support filter", stanza.FilterVerb))
+	}
+	if stanza.PrioritizeVerb != want.PrioritizeVerb {
+		problems = append(problems, fmt.Sprintf("prioritizeVerb is %q, want %q", stanza.PrioritizeVerb, want.PrioritizeVerb))
+	}
+	if stanza.PreemptVerb != "" && stanza.PreemptVerb != want.PreemptVerb {
+		problems = append(problems, fmt.Sprintf("preemptVerb is %q, want %q", stanza.PreemptVerb, want.PreemptVerb))
+	}
+	if stanza.BindVerb != "" && stanza.BindVerb != want.BindVerb {
+		if want.BindVerb == "" {
+			problems = append(problems, "bindVerb is set but bind is not enabled on master")
+		} else {
+			problems = append(problems, fmt.Sprintf("bindVerb is %q, want %q", stanza.BindVerb, want.BindVerb))
+		}
+	}
+	if stanza.Weight <= 0 {
+		problems = append(problems, "weight must be positive when prioritizeVerb is set")
+	}
+	// nodeCacheCapable 决定 preempt 收到的是完整的 victims 还是只有 UID 的 MetaVictims
+	if stanza.NodeCacheCapable != want.NodeCacheCapable {
+		problems = append(problems, fmt.Sprintf("nodeCacheCapable is %v, want %v", stanza.NodeCacheCapable, want.NodeCacheCapable))
+	}
+	if https := c.extenderHTTPS(); stanza.enableHTTPS != https {
+		problems = append(problems, fmt.Sprintf("%s is %v, want %v", stanza.httpsField, stanza.enableHTTPS, https))
+	}
+	if stanza.enableHTTPS && !stanza.TLSConfig.hasCA() {
+		problems = append(problems, fmt.Sprintf("%s is true but tlsConfig has no caFile or caData", stanza.httpsField))
+	}
+	if listenerTLS, _ := c.Listeners.Scheduler.security(c); stanza.enableHTTPS && listenerTLS.RequireSchedulerClientCert && !stanza.TLSConfig.hasClientCert() {
+		problems = append(problems, "tlsConfig has no client certificate but master requires scheduler client certificates")
+	}
+	if timeout, err := parseHTTPTimeout(stanza.HTTPTimeout); err != nil {
+		problems = append(problems, err.Error())
+	} else if timeout > 0 && timeout < time.Second {
+		problems = append(problems, fmt.Sprintf("httpTimeout %s is too short", timeout))
+	}
+	return problems, nil
+}
+
+// parseHTTPTimeout Policy 中为纳秒数，KubeSchedulerConfiguration 中为 "5s" 形式的字符串
+func parseHTTPTimeout(v interface{}) (time.Duration, error) {
+	switch t := v.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return time.Duration(t), nil
+	case int64:
+		return time.Duration(t), nil
+	case float64:
+		return time.Duration(t), nil
+	case string:
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return 0, fmt.Errorf("invalid httpTimeout %q: %v", t, err)
+		}
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid httpTimeout %v", v)
+}
+
+// schedConfigCmd master schedconfig [-config master.json] [-format policy|kubeschedulerconfiguration] [-ca-file ...] [-cert-file ...] [-key-file ...] [-server-name ...]
+func schedConfigCmd(args []string) int {
+	fs := flag.NewFlagSet("schedconfig", flag.ExitOnError)
+	configPath := fs.String("config", "", "path of the json config file")
+	format := fs.String("format", "kubeschedulerconfiguration", "policy or kubeschedulerconfiguration")
+	caFile := fs.String("ca-file", "", "CA used by the scheduler to verify master, overrides extender.tls.ca_file")
+	certFile := fs.String("cert-file", "", "client certificate of the scheduler, overrides extender.tls.cert_file")
+	keyFile := fs.String("key-file", "", "client key of the scheduler, overrides extender.tls.key_file")
+	serverName := fs.String("server-name", "", "server name in the certificate of master, overrides extender.tls.server_name")
+	_ = fs.Parse(args)
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for flagValue, field := range map[*string]*string{
+		caFile:     &config.Extender.TLS.CAFile,
+		certFile:   &config.Extender.TLS.CertFile,
+		keyFile:    &config.Extender.TLS.KeyFile,
+		serverName: &config.Extender.TLS.ServerName,
+	} {
+		if *flagValue != "" {
+			*field = *flagValue
+		}
+	}
+	var data []byte
+	switch *format {
+	case "policy":
+		data, err = legacyPolicy(config)
+	case "kubeschedulerconfiguration":
+		data, err = kubeSchedulerConfiguration(config)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(strings.TrimSpace(string(data)))
+	return 0
+}
+
+// validateSchedConfigCmd master validate-schedconfig [-config master.json] <scheduler config file>
+func validateSchedConfigCmd(args []string) int {
+	fs := flag.NewFlagSet("validate-schedconfig", flag.ExitOnError)
+	configPath := fs.String("config", "", "path of the json config file")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: master validate-schedconfig [-config master.json] <scheduler config file>")
+		return 2
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	problems, err := validateSchedulerConfig(config, data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	fmt.Println("ok")
+	return 0
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func httpsConfig() *Config {
+	c := defaultConfig()
+	c.TLS = TLSConfig{CertFile: "server.crt", KeyFile: "server.key"}
+	c.Extender.TLS.CAFile = "/etc/kubernetes/master-ca.crt"
+	c.Bind = true
+	return c
+}
+
+// mtlsConfig scheduler 的监听地址要求客户端证书
+func mtlsConfig() *Config {
+	c := defaultConfig()
+	c.Listeners.Scheduler = ListenerConfig{Addr: ":9443", TLS: &TLSConfig{
+		CertFile: "server.crt", KeyFile: "server.key", ClientCAFile: "ca.crt", RequireSchedulerClientCert: true,
+	}}
+	c.Extender.TLS = ExtenderTLSConfig{
+		CAFile: "master-ca.crt", CertFile: "scheduler.crt", KeyFile: "scheduler.key", ServerName: "master.kube-system.svc",
+	}
+	return c
+}
+
+func TestGeneratedSchedulerConfigValidates(t *testing.T) {
+	for _, c := range []*Config{defaultConfig(), httpsConfig(), mtlsConfig()} {
+		for name, gen := range map[string]func(*Config) ([]byte, error){
+			"policy":                     legacyPolicy,
+			"kubeschedulerconfiguration": kubeSchedulerConfiguration,
+		} {
+			data, err := gen(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			problems, err := validateSchedulerConfig(c, data)
+			if err != nil || len(problems) > 0 {
+				t.Errorf("%s: %v %v\n%s", name, err, problems, data)
+			}
+		}
+	}
+}
+
+func TestSchedulerConfigHTTPSFieldNames(t *testing.T) {
+	c := httpsConfig()
+	policy, _ := legacyPolicy(c)
+	if !strings.Contains(string(policy), `"enableHttps": true`) || strings.Contains(string(policy), "enableHTTPS") {
+		t.Errorf("policy should use enableHttps:\n%s", policy)
+	}
+	kube, _ := kubeSchedulerConfiguration(c)
+	if !strings.Contains(string(kube), "enableHTTPS: true") || strings.Contains(string(kube), "enableHttps") {
+		t.Errorf("KubeSchedulerConfiguration should use enableHTTPS:\n%s", kube)
+	}
+}
+
+func TestSchedulerConfigTLS(t *testing.T) {
+	kube, err := kubeSchedulerConfiguration(mtlsConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"urlPrefix: https://127.0.0.1:9443/api/v1/k8sextension", "tlsConfig:",
+		"serverName: master.kube-system.svc", "certFile: scheduler.crt", "keyFile: scheduler.key", "caFile: master-ca.crt"} {
+		if !strings.Contains(string(kube), want) {
+			t.Errorf("KubeSchedulerConfiguration should contain %q:\n%s", want, kube)
+		}
+	}
+	policy, err := legacyPolicy(httpsConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(policy), `"caFile": "/etc/kubernetes/master-ca.crt"`) {
+		t.Errorf("policy should contain tlsConfig.caFile:\n%s", policy)
+	}
+	if plain, _ := kubeSchedulerConfiguration(defaultConfig()); strings.Contains(string(plain), "tlsConfig") {
+		t.Errorf("http config should not contain tlsConfig:\n%s", plain)
+	}
+
+	noCA := httpsConfig()
+	noCA.Extender.TLS.CAFile = ""
+	if _, err := kubeSchedulerConfiguration(noCA); err == nil {
+		t.Error("expected an error for https without a CA")
+	}
+	// 不使用监听地址的 client_ca_file 作为 CA
+	noCA = mtlsConfig()
+	noCA.Extender.TLS.CAFile = ""
+	if _, err := kubeSchedulerConfiguration(noCA); err == nil {
+		t.Error("expected an error for https without an explicit CA")
+	}
+	noCert := mtlsConfig()
+	noCert.Extender.TLS.KeyFile = ""
+	if _, err := legacyPolicy(noCert); err == nil {
+		t.Error("expected an error for a missing scheduler client certificate")
+	}
+}
+
+func TestValidateSchedulerConfig(t *testing.T) {
+	c := httpsConfig()
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "policy",
+			data: `{"kind": "Policy", "apiVersion": "v1", "extenders": [{"urlPrefix": "https://127.0.0.1:8080/api/v1/k8sextension/",
+				"prioritizeVerb": "prioritize", "bindVerb": "bind", "weight": 1, "enableHttps": true, "httpTimeout": 5000000000,
+				"tlsConfig": {"caFile": "/etc/kubernetes/master-ca.crt"}}]}`,
+		},
+		{
+			name: "https without a CA",
+			data: `{"kind": "Policy", "extenders": [{"urlPrefix": "https://127.0.0.1:8080/api/v1/k8sextension",
+				"prioritizeVerb": "prioritize", "weight": 1, "enableHttps": true, "tlsConfig": {"certFile": "scheduler.crt"}}]}`,
+			want: []string{"enableHttps is true but tlsConfig has no caFile or caData"},
+		},
+		{
+			name: "policy with the KubeSchedulerConfiguration field name",
+			data: `{"kind": "Policy", "extenders": [{"urlPrefix": "https://127.0.0.1:8080/api/v1/k8sextension",
+				"prioritizeVerb": "prioritize", "weight": 1, "enableHTTPS": true}]}`,
+			want: []string{"enableHttps is false, want true"},
+		},
+		{
+			name: "kube scheduler configuration",
+			data: `
+kind: KubeSchedulerConfiguration
+extenders:
+- urlPrefix: https://127.0.0.1:8080/api/v1/k8sextension
+  filterVerb: filter
+  prioritizeVerb: prioritize
+  preemptVerb: evict
+  weight: 0
+  enableHTTPS: false
+  httpTimeout: 100ms
+`,
+			want: []string{
+				`filterVerb "filter" is set but master does not support filter`,
+				`preemptVerb is "evict", want "preempt"`,
+				"weight must be positive when prioritizeVerb is set",
+				"enableHTTPS is false, want true",
+				"httpTimeout 100ms is too short",
+			},
+		},
+		{
+			name: "node cache capable",
+			data: `{"kind": "Policy", "extenders": [{"urlPrefix": "https://127.0.0.1:8080/api/v1/k8sextension",
+				"prioritizeVerb": "prioritize", "weight": 1, "nodeCacheCapable": true, "enableHttps": true,
+				"tlsConfig": {"caFile": "/etc/kubernetes/master-ca.crt"}}]}`,
+			want: []string{"nodeCacheCapable is true, want false"},
+		},
+		{
+			name: "other extender",
+			data: `{"kind": "Policy", "extenders": [{"urlPrefix": "http://10.0.0.1/other"}]}`,
+			want: []string{"no extender with urlPrefix https://127.0.0.1:8080/api/v1/k8sextension"},
+		},
+	}
+	for _, tt := range tests {
+		problems, err := validateSchedulerConfig(c, []byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(problems, tt.want) {
+			t.Errorf("%s: problems = %q, want %q", tt.name, problems, tt.want)
+		}
+	}
+	problems, err := validateSchedulerConfig(mtlsConfig(), []byte(`
+kind: KubeSchedulerConfiguration
+extenders:
+- urlPrefix: https://127.0.0.1:9443/api/v1/k8sextension
+  prioritizeVerb: prioritize
+  preemptVerb: preempt
+  weight: 1
+  enableHTTPS: true
+  tlsConfig:
+    caData: Y2E=
+`))
+	want := []string{"tlsConfig has no client certificate but master requires scheduler client certificates"}
+	if err != nil || !reflect.DeepEqual(problems, want) {
+		t.Errorf("mtls: problems = %q, %v, want %q", problems, err, want)
+	}
+	if _, err := validateSchedulerConfig(c, []byte(`{"kind": "Deployment"}`)); err == nil {
+		t.Error("expected an error for an unsupported kind")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"systeminfoagent/tlsutil"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TLSConfig master 的 TLS 配置，CertFile 为空时使用 http
+type TLSConfig struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// ClientCAFile 用于校验 agent 的客户端证书
+	ClientCAFile string `json:"client_ca_file"`
+	// RequireAgentClientCert 为 true 时 agent 上报数据的接口必须提供由 ClientCAFile 签发的证书
+	RequireAgentClientCert bool `json:"require_agent_client_cert"`
+	// RequireSchedulerClientCert 为 true 时 k8sextension 接口必须提供由 ClientCAFile 签发的证书
+	RequireSchedulerClientCert bool `json:"require_scheduler_client_cert"`
+}
+
+func (c *TLSConfig) enabled() bool {
+	return c.CertFile != ""
+}
+
+func (c *TLSConfig) validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("tls.cert_file and tls.key_file must be set together")
+	}
+	if c.ClientCAFile != "" && !c.enabled() {
+		return fmt.Errorf("tls.client_ca_file requires tls.cert_file")
+	}
+	if c.RequireAgentClientCert && c.ClientCAFile == "" {
+		return fmt.Errorf("tls.require_agent_client_cert requires tls.client_ca_file")
+	}
+	if c.RequireSchedulerClientCert && c.ClientCAFile == "" {
+		return fmt.Errorf("tls.require_scheduler_client_cert requires tls.client_ca_file")
+	}
+	return nil
+}
+
+// serverTLSConfig 证书文件被修改后会自动重新加载
+func (c *TLSConfig) serverTLSConfig() (*tls.Config, error) {
+	if !c.enabled() {
+		return nil, nil
+	}
+	return tlsutil.ServerConfig(c.CertFile, c.KeyFile, c.ClientCAFile)
+}
+
+// requireClientCert 要求请求提供经过校验的客户端证书
+func requireClientCert(c *gin.Context) {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		log.Printf("[audit] reject %s %s from %s: no verified client certificate", c.Request.Method, c.Request.URL.Path, remoteIP(c))
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	c.Next()
+}
+
+// serve 根据 tlsConfig 是否为 nil 使用 https 或 http
+func serve(server *http.Server, tlsConfig *tls.Config) error {
+	if tlsConfig == nil {
+		return server.ListenAndServe()
+	}
+	// 证书由 TLSConfig.GetCertificate 提供
+	server.TLSConfig = tlsConfig
+	return server.ListenAndServeTLS("", "")
+}
+
+// genCertsCmd master gencerts [-dir .] [-hosts 127.0.0.1,localhost] [-agents node1,node2]
+// 生成本地测试用的 CA、master 证书以及 agent 客户端证书，agent 证书的 CN 为节点 ID
+func genCertsCmd(args []string) int {
+	fs := flag.NewFlagSet("gencerts", flag.ExitOnError)
+	dir := fs.String("dir", ".", "output directory")
+	hosts := fs.String("hosts", "127.0.0.1,localhost", "comma separated hosts of the master certificate")
+	agents := fs.String("agents", "", "comma separated node ids to generate agent client certificates for")
+	validFor := fs.Duration("valid-for", 365*24*time.Hour, "validity of the certificates")
+	_ = fs.Parse(args)
+
+	ca, err := tlsutil.GenerateCA("systeminfoagent-ca", *validFor)
+	if err == nil {
+		err = ca.WriteFiles(*dir, "ca")
+	}
+	if err == nil {
+		var server *tlsutil.KeyPair
+		if server, err = tlsutil.GenerateCert(ca, "master", strings.Split(*hosts, ","), *validFor); err == nil {
+			err = server.WriteFiles(*dir, "master")
+		}
+	}
+	for _, agent := range strings.Split(*agents, ",") {
+		if err != nil || agent == "" {
+			continue
+		}
+		var client *tlsutil.KeyPair
+		if client, err = tlsutil.GenerateCert(ca, agent, nil, *validFor); err == nil {
+			err = client.WriteFiles(*dir, "agent-"+agent)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"systeminfoagent/tlsutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTLSConfigValidate(t *testing.T) {
+	tests := []struct {
+		config TLSConfig
+		ok     bool
+	}{
+		{TLSConfig{}, true},
+		{TLSConfig{CertFile: "a.crt", KeyFile: "a.key"}, true},
+		{TLSConfig{CertFile: "a.crt"}, false},
+		{TLSConfig{ClientCAFile: "ca.crt"}, false},
+		{TLSConfig{CertFile: "a.crt", KeyFile: "a.key", RequireAgentClientCert: true}, false},
+		{TLSConfig{CertFile: "a.crt", KeyFile: "a.key", ClientCAFile: "ca.crt", RequireAgentClientCert: true}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v: validate() = %v, want ok %v", tt.config, err, tt.ok)
+		}
+	}
+}
+
+// writeTestCerts 生成 ca、master 证书、CN 为 node-1 的 agent 证书以及 CN 为 kube-scheduler 的 scheduler 证书
+func writeTestCerts(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	ca, err := tlsutil.GenerateCA("test-ca", time.Hour)
+	if err == nil {
+		err = ca.WriteFiles(dir, "ca")
+	}
+	var server, agent *tlsutil.KeyPair
+	if err == nil {
+		server, err = tlsutil.GenerateCert(ca, "master", []string{"127.0.0.1"}, time.Hour)
+	}
+	if err == nil {
+		err = server.WriteFiles(dir, "master")
+	}
+	if err == nil {
+		agent, err = tlsutil.GenerateCert(ca, "node-1", nil, time.Hour)
+	}
+	if err == nil {
+		err = agent.WriteFiles(dir, "agent-node-1")
+	}
+	var scheduler *tlsutil.KeyPair
+	if err == nil {
+		scheduler, err = tlsutil.GenerateCert(ca, "kube-scheduler", nil, time.Hour)
+	}
+	if err == nil {
+		err = scheduler.WriteFiles(dir, "scheduler")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// startTLSEngine 使用 config 启动 https 服务，返回 https 地址
+func startTLSEngine(t *testing.T, engine *gin.Engine, config TLSConfig) string {
+	t.Helper()
+	serverTLS, err := config.serverTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(engine)
+	server.Listener = tls.NewListener(server.Listener, serverTLS)
+	server.Start()
+	t.Cleanup(server.Close)
+	return strings.Replace(server.URL, "http://", "https://", 1)
+}
+
+// tlsStatus 使用 dir 中名为 cert 的客户端证书请求 url，cert 为空时不提供证书
+func tlsStatus(t *testing.T, dir, cert, method, url string) int {
+	t.Helper()
+	var certFile, keyFile string
+	if cert != "" {
+		certFile, keyFile = filepath.Join(dir, cert+".crt"), filepath.Join(dir, cert+".key")
+	}
+	clientTLS, err := tlsutil.ClientConfig(filepath.Join(dir, "ca.crt"), certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func testTLSConfig(dir string) TLSConfig {
+	return TLSConfig{
+		CertFile:     filepath.Join(dir, "master.crt"),
+		KeyFile:      filepath.Join(dir, "master.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	dir := writeTestCerts(t)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/open", func(c *gin.Context) { c.Status(http.StatusOK) })
+	engine.GET("/agent", requireClientCert, func(c *gin.Context) { c.Status(http.StatusOK) })
+	url := startTLSEngine(t, engine, testTLSConfig(dir))
+
+	if code := tlsStatus(t, dir, "agent-node-1", http.MethodGet, url+"/agent"); code != http.StatusOK {
+		t.Errorf("agent with client cert: %d", code)
+	}
+	if code := tlsStatus(t, dir, "", http.MethodGet, url+"/agent"); code != http.StatusUnauthorized {
+		t.Errorf("agent without client cert: %d", code)
+	}
+	if code := tlsStatus(t, dir, "", http.MethodGet, url+"/open"); code != http.StatusOK {
+		t.Errorf("open route without client cert: %d", code)
+	}
+}
@@ -17,10 +17,14 @@ type NodeFullMetric struct {
 }
 
 type Statistics struct {
-	CPU     MetricStatistics `json:"cpu"`
-	Memory  MetricStatistics `json:"memory"`
-	Network MetricStatistics `json:"network"`
-	Disk    MetricStatistics `json:"disk"`
+	CPU      MetricStatistics `json:"cpu"`
+	Memory   MetricStatistics `json:"memory"`
+	Network  MetricStatistics `json:"network"`
+	Disk     MetricStatistics `json:"disk"`
+	Load     MetricStatistics `json:"load"`
+	Pressure MetricStatistics `json:"pressure"`
+	DiskIO   MetricStatistics `json:"disk_io"`
+	Sockets  MetricStatistics `json:"sockets"`
 }
 
 type MetricStatistics struct {
@@ -40,17 +44,45 @@ type NodeMetric struct {
 	Memory    Memory    `json:"memory"`
 	Network   Network   `json:"network"`
 	Disk      Disk      `json:"disk"`
+	Load      Load      `json:"load"`
+	Pressure  Pressure  `json:"pressure"`
+	Sockets   Sockets   `json:"sockets"`
 }
 
 type NodeInfo struct {
 	ID string `json:"id"`
+	// Address 上报数据的来源地址，由 master 填写
+	Address string `json:"address,omitempty"`
 }
 
+// CPU 一段时间内各状态的 jiffies，与 /proc/stat 相同，User 和 Nice 中已经包含了 Guest 和 GuestNice
 type CPU struct {
-	Valid  bool   `json:"valid"`
-	User   uint64 `json:"user"`
-	System uint64 `json:"system"`
-	Idle   uint64 `json:"idle"`
+	Valid     bool   `json:"valid"`
+	User      uint64 `json:"user"`
+	System    uint64 `json:"system"`
+	Idle      uint64 `json:"idle"`
+	Nice      uint64 `json:"nice"`
+	IOWait    uint64 `json:"iowait"`
+	IRQ       uint64 `json:"irq"`
+	SoftIRQ   uint64 `json:"softirq"`
+	Steal     uint64 `json:"steal"`
+	Guest     uint64 `json:"guest"`
+	GuestNice uint64 `json:"guest_nice"`
+	// PerCore 只有 agent 开启了 per-core 模式时才有数据
+	PerCore *PerCore `json:"per_core,omitempty"`
+}
+
+// PerCore 每个 CPU 的繁忙百分比（iowait 视为空闲）以及汇总
+type PerCore struct {
+	Busy    []float64 `json:"busy"`
+	MaxBusy float64   `json:"max_busy"`
+	Mean    float64   `json:"mean"`
+	StdDev  float64   `json:"std_dev"`
+}
+
+// Total 所有状态的 jiffies 之和，Guest 已经计入 User，不重复计算
+func (c CPU) Total() uint64 {
+	return c.User + c.Nice + c.System + c.Idle + c.IOWait + c.IRQ + c.SoftIRQ + c.Steal
 }
 
 type Memory struct {
@@ -59,12 +91,38 @@ type Memory struct {
 	Used   uint64 `json:"used"`
 	Cached uint64 `json:"cached"`
 	Free   uint64 `json:"free"`
+	// Available /proc/meminfo 中的 MemAvailable，包含可以回收的 page cache，内核不支持时为 0
+	Available uint64 `json:"available"`
+	Buffers   uint64 `json:"buffers"`
+	SwapTotal uint64 `json:"swap_total"`
+	SwapUsed  uint64 `json:"swap_used"`
+	// SwapIn SwapOut 每秒换入换出的页数
+	SwapIn  uint64 `json:"swap_in"`
+	SwapOut uint64 `json:"swap_out"`
+}
+
+// AvailableBytes 可以分配给新进程的内存，没有 MemAvailable 时（旧内核或者旧版本的 agent）
+// 使用 Free + Buffers + Cached 估算
+func (m Memory) AvailableBytes() uint64 {
+	if m.Available > 0 {
+		return m.Available
+	}
+	return m.Free + m.Buffers + m.Cached
 }
 
 type Network struct {
 	Valid   bool   `json:"valid"`
 	RxBytes uint64 `json:"rx_bytes"`
 	TxBytes uint64 `json:"tx_bytes"`
+	// 以下均为每秒的数量
+	RxPackets uint64 `json:"rx_packets"`
+	TxPackets uint64 `json:"tx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	TxErrors  uint64 `json:"tx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxDropped uint64 `json:"tx_dropped"`
+	// SpeedMbps 网卡的协商速率，虚拟网卡等无法获取时为 0
+	SpeedMbps uint64 `json:"speed_mbps"`
 }
 
 type Disk struct {
@@ -74,4 +132,140 @@ type Disk struct {
 	Free       uint64 `json:"free"`
 	WriteTimes uint64 `json:"write_times"`
 	ReadTimes  uint64 `json:"read_times"`
+	// Inodes 为 0 表示文件系统不提供 inode 数量（或者旧版本的 agent）
+	Inodes     uint64 `json:"inodes"`
+	InodesUsed uint64 `json:"inodes_used"`
+	InodesFree uint64 `json:"inodes_free"`
+	// Available 普通用户可用的字节数，Free 中还包括了为 root 保留的空间
+	Available uint64 `json:"available"`
+	FsType    string `json:"fs_type,omitempty"`
+	ReadOnly  bool   `json:"read_only"`
+	// Mounts 节点上所有存储数据的文件系统，不包括 tmpfs、overlay 等
+	Mounts []MountUsage `json:"mounts,omitempty"`
+	// Devices 各个块设备的 I/O 情况，与 Valid 无关，旧版本的 agent 不上报
+	Devices []DiskIO `json:"devices,omitempty"`
+}
+
+// MountUsage 单个挂载点的使用情况
+type MountUsage struct {
+	Path       string `json:"path"`
+	Device     string `json:"device"`
+	FsType     string `json:"fs_type"`
+	ReadOnly   bool   `json:"read_only"`
+	Size       uint64 `json:"size"`
+	Used       uint64 `json:"used"`
+	Free       uint64 `json:"free"`
+	Available  uint64 `json:"available"`
+	Inodes     uint64 `json:"inodes"`
+	InodesFree uint64 `json:"inodes_free"`
+}
+
+// DiskIO 单个块设备在采集间隔内的 I/O 情况
+type DiskIO struct {
+	Device     string  `json:"device"`
+	ReadIOPS   float64 `json:"read_iops"`
+	WriteIOPS  float64 `json:"write_iops"`
+	ReadBytes  uint64  `json:"read_bytes"`  // 每秒读取的字节数
+	WriteBytes uint64  `json:"write_bytes"` // 每秒写入的字节数
+	AwaitMs    float64 `json:"await_ms"`    // 每次 I/O 的平均耗时（包括排队）
+	QueueDepth float64 `json:"queue_depth"` // 平均队列长度
+	Util       float64 `json:"util"`        // 有 I/O 在处理的时间百分比
+}
+
+// BusiestDevice 返回 Util 最高的设备，没有设备数据时返回 false
+func (d Disk) BusiestDevice() (DiskIO, bool) {
+	if len(d.Devices) == 0 {
+		return DiskIO{}, false
+	}
+	busiest := d.Devices[0]
+	for _, device := range d.Devices[1:] {
+		if device.Util > busiest.Util {
+			busiest = device
+		}
+	}
+	return busiest, true
+}
+
+// Load 系统负载，Runnable 和 Blocked 为采集时刻的瞬时值
+type Load struct {
+	Valid    bool    `json:"valid"`
+	Load1    float64 `json:"load1"`
+	Load5    float64 `json:"load5"`
+	Load15   float64 `json:"load15"`
+	Runnable uint64  `json:"runnable"` // 可运行（运行中或等待 CPU）的任务数
+	Blocked  uint64  `json:"blocked"`  // 等待 I/O 而阻塞的任务数
+	CPUs     int     `json:"cpus"`     // 在线的 CPU 数
+}
+
+// LoadPerCore 平均到每个 CPU 上的 1 分钟负载，CPU 数未知时返回 Load1
+func (l Load) LoadPerCore() float64 {
+	if l.CPUs <= 0 {
+		return l.Load1
+	}
+	return l.Load1 / float64(l.CPUs)
+}
+
+// Pressure /proc/pressure 中的 PSI 数据，内核不支持 PSI 时 Valid 为 false
+type Pressure struct {
+	Valid  bool             `json:"valid"`
+	CPU    ResourcePressure `json:"cpu"`
+	Memory ResourcePressure `json:"memory"`
+	IO     ResourcePressure `json:"io"`
+}
+
+// ResourcePressure some 表示至少有一个任务因为该资源停顿，full 表示所有非空闲任务同时停顿
+type ResourcePressure struct {
+	Some PressureStat `json:"some"`
+	Full PressureStat `json:"full"`
+}
+
+// PressureStat Avg 为停顿时间所占的百分比，Total 为累计停顿的微秒数
+type PressureStat struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total"`
+}
+
+// MaxSome10 cpu、memory 和 io 中最大的 some avg10
+func (p Pressure) MaxSome10() float64 {
+	max := p.CPU.Some.Avg10
+	if p.Memory.Some.Avg10 > max {
+		max = p.Memory.Some.Avg10
+	}
+	if p.IO.Some.Avg10 > max {
+		max = p.IO.Some.Avg10
+	}
+	return max
+}
+
+// Sockets TCP 连接以及相关的内核上限，上限为 0 表示无法获取（例如没有加载 conntrack 模块）
+type Sockets struct {
+	Valid bool `json:"valid"`
+	// TCPStates 按状态（ESTABLISHED、TIME_WAIT 等）统计的 IPv4 和 IPv6 TCP socket 数量
+	TCPStates      map[string]uint64 `json:"tcp_states"`
+	TimeWaitMax    uint64            `json:"time_wait_max"` // net.ipv4.tcp_max_tw_buckets
+	ConntrackCount uint64            `json:"conntrack_count"`
+	ConntrackMax   uint64            `json:"conntrack_max"`
+	// EphemeralPortsUsed 本地端口在 ip_local_port_range 范围内的不同端口数
+	EphemeralPortsUsed  uint64 `json:"ephemeral_ports_used"`
+	EphemeralPortsTotal uint64 `json:"ephemeral_ports_total"`
+}
+
+// MaxUsagePercent TIME_WAIT、conntrack 和临时端口中最接近上限的一项的使用百分比
+func (s Sockets) MaxUsagePercent() float64 {
+	var max float64
+	for _, usage := range [][2]uint64{
+		{s.TCPStates["TIME_WAIT"], s.TimeWaitMax},
+		{s.ConntrackCount, s.ConntrackMax},
+		{s.EphemeralPortsUsed, s.EphemeralPortsTotal},
+	} {
+		if usage[1] == 0 {
+			continue
+		}
+		if percent := float64(usage[0]) / float64(usage[1]) * 100.0; percent > max {
+			max = percent
+		}
+	}
+	return max
 }
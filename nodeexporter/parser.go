@@ -0,0 +1,158 @@
+package nodeexporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Sample node_exporter 文本格式中的一条数据
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// Samples 一次抓取得到的全部数据，按指标名分组
+type Samples map[string][]Sample
+
+// Find 返回名称为 name 且包含全部 labels 的第一条数据
+func (s Samples) Find(name string, labels map[string]string) (float64, bool) {
+	for _, sample := range s[name] {
+		if matchLabels(sample.Labels, labels) {
+			return sample.Value, true
+		}
+	}
+	return 0, false
+}
+
+// Sum 返回名称为 name 且包含全部 labels 的所有数据之和
+func (s Samples) Sum(name string, labels map[string]string) (float64, bool) {
+	var total float64
+	var found bool
+	for _, sample := range s[name] {
+		if matchLabels(sample.Labels, labels) {
+			total += sample.Value
+			found = true
+		}
+	}
+	return total, found
+}
+
+// All 返回名称为 name 且包含全部 labels 的所有数据
+func (s Samples) All(name string, labels map[string]string) []Sample {
+	var res []Sample
+	for _, sample := range s[name] {
+		if matchLabels(sample.Labels, labels) {
+			res = append(res, sample)
+		}
+	}
+	return res
+}
+
+func matchLabels(have, want map[string]string) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse 解析 prometheus 文本格式（text/plain; version=0.0.4）
+// 只关心指标名、标签和值，注释、HELP、TYPE 以及时间戳都会被忽略
+func Parse(r io.Reader) (Samples, error) {
+	samples := Samples{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sample, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		samples[sample.Name] = append(samples[sample.Name], sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read metrics: %v", err)
+	}
+	return samples, nil
+}
+
+func parseLine(line string) (Sample, error) {
+	sample := Sample{Labels: map[string]string{}}
+	var rest string
+	if idx := strings.IndexByte(line, '{'); idx >= 0 {
+		sample.Name = line[:idx]
+		end, err := parseLabels(line[idx+1:], sample.Labels)
+		if err != nil {
+			return sample, err
+		}
+		rest = line[idx+1+end:]
+	} else {
+		fields := strings.Fields(line)
+		sample.Name, rest = fields[0], strings.TrimPrefix(line, fields[0])
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return sample, fmt.Errorf("missing value for %s", sample.Name)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("parse value of %s: %v", sample.Name, err)
+	}
+	sample.Value = value
+	return sample, nil
+}
+
+// parseLabels 解析 `a="1",b="2"}` 形式的标签，返回 '}' 之后的位置
+func parseLabels(s string, labels map[string]string) (int, error) {
+	i := 0
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return 0, fmt.Errorf("unterminated label set")
+		}
+		if s[i] == '}' {
+			return i + 1, nil
+		}
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 {
+			return 0, fmt.Errorf("invalid label near %q", s[i:])
+		}
+		key := strings.TrimSpace(s[i : i+eq])
+		i += eq + 1
+		if i >= len(s) || s[i] != '"' {
+			return 0, fmt.Errorf("label %s: value not quoted", key)
+		}
+		i++
+		var value strings.Builder
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return 0, fmt.Errorf("label %s: unterminated value", key)
+		}
+		i++
+		labels[key] = value.String()
+	}
+}
@@ -0,0 +1,62 @@
+package nodeexporter
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	f, err := os.Open("testdata/metrics.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	samples, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := samples.Find("node_memory_MemTotal_bytes", nil); !ok || v != 8e9 {
+		t.Errorf("MemTotal = %v, %v", v, ok)
+	}
+	if v, ok := samples.Find("node_filesystem_size_bytes", map[string]string{"mountpoint": "/run"}); !ok || v != 1e9 {
+		t.Errorf("size of /run = %v, %v", v, ok)
+	}
+	if v, ok := samples.Sum("node_cpu_seconds_total", map[string]string{"mode": "idle"}); !ok || v != 200 {
+		t.Errorf("idle = %v, %v", v, ok)
+	}
+	if n := len(samples.All("node_cpu_seconds_total", map[string]string{"mode": "user"})); n != 2 {
+		t.Errorf("user samples = %d, want 2", n)
+	}
+	// 时间戳被忽略
+	if v, ok := samples.Find("node_load1", nil); !ok || v != 0.5 {
+		t.Errorf("load1 = %v, %v", v, ok)
+	}
+	if _, ok := samples.Find("node_missing", nil); ok {
+		t.Error("found a missing metric")
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	samples, err := Parse(strings.NewReader(`m{a="x\"y",b="1\\2",c="l\n"} 3` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := samples["m"][0].Labels
+	if labels["a"] != `x"y` || labels["b"] != `1\2` || labels["c"] != "l\n" {
+		t.Errorf("labels = %q", labels)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"m{a=\"1\" 3\n",
+		"m{a=1} 3\n",
+		"m{a=\"1\"}\n",
+		"m abc\n",
+	} {
+		if _, err := Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Parse(%q) succeeded", input)
+		}
+	}
+}
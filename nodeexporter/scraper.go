@@ -0,0 +1,497 @@
+package nodeexporter
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"systeminfoagent/model"
+	"time"
+)
+
+// userHZ node_exporter 以秒为单位给出 cpu 时间，而 agent 上报的是 jiffies
+const userHZ = 100
+
+// Target 一个 node_exporter 抓取地址
+type Target struct {
+	NodeID    string `json:"node_id"`
+	URL       string `json:"url"`
+	Device    string `json:"device"`    // 块设备名，为空时使用挂载点所在的设备，见 diskDevice
+	Interface string `json:"interface"` // 网卡名，为空时使用默认路由所在的网卡，见 defaultInterface
+	Mount     string `json:"mount"`     // 挂载点，默认 /
+}
+
+// DefaultTimeout client 为 nil 时抓取单个 target 的超时时间
+// 抓取间隔通常只有 1 秒，node_exporter 在负载较高的节点上可能需要更久才能返回
+const DefaultTimeout = 5 * time.Second
+
+// counters 计算差值所需要的累计值
+type counters struct {
+	timestamp  time.Time
+	device     string
+	iface      string
+	cpuUser    float64
+	cpuSystem  float64
+	cpuIdle    float64
+	cpuNice    float64
+	cpuIOWait  float64
+	cpuIRQ     float64
+	cpuSoft    float64
+	cpuSteal   float64
+	cpuGuest   float64
+	cpuGNice   float64
+	reads      float64
+	writes     float64
+	readBytes  float64
+	writeBytes float64
+	readTime   float64
+	writeTime  float64
+	ioTime     float64
+	ioWeighted float64
+	rxBytes    float64
+	txBytes    float64
+	rxPackets  float64
+	txPackets  float64
+	rxErrors   float64
+	txErrors   float64
+	rxDropped  float64
+	txDropped  float64
+	swapIn     float64
+	swapOut    float64
+	cpuOK      bool
+	diskOK     bool
+	ioOK       bool
+	netOK      bool
+	swapOK     bool
+}
+
+// Scraper 定期抓取 node_exporter，转换为 model.NodeMetric
+// 第一次抓取只记录累计值，从第二次开始才产生数据
+type Scraper struct {
+	client   *http.Client
+	interval time.Duration
+	targets  func() []Target
+
+	lock sync.Mutex
+	prev map[string]counters
+}
+
+// NewScraper targets 在每轮抓取前调用，可以返回静态配置或者动态发现的地址
+// client 为 nil 时使用超时为 DefaultTimeout 的 client
+func NewScraper(client *http.Client, interval time.Duration, targets func() []Target) *Scraper {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &Scraper{
+		client:   client,
+		interval: interval,
+		targets:  targets,
+		prev:     map[string]counters{},
+	}
+}
+
+// Run 阻塞运行，直到 stop 被关闭
+func (s *Scraper) Run(stop <-chan struct{}, out chan<- *model.NodeMetric) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.ScrapeAll(out)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScrapeAll 并发抓取所有 target，已经不在 targets 中的节点的累计值会被删除
+func (s *Scraper) ScrapeAll(out chan<- *model.NodeMetric) {
+	var wg = sync.WaitGroup{}
+	targets := s.targets()
+	s.prune(targets)
+	wg.Add(len(targets))
+	for _, target := range targets {
+		go func(target Target) {
+			defer wg.Done()
+			metric, err := s.Scrape(target)
+			if err != nil {
+				log.Printf("[err] scrape %s (%s): %v", target.NodeID, target.URL, err)
+				return
+			}
+			if metric != nil {
+				out <- metric
+			}
+		}(target)
+	}
+	wg.Wait()
+}
+
+func (s *Scraper) prune(targets []Target) {
+	current := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		current[target.NodeID] = true
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for id := range s.prev {
+		if !current[id] {
+			delete(s.prev, id)
+		}
+	}
+}
+
+// Scrape 抓取单个 target，第一次抓取时返回 nil
+func (s *Scraper) Scrape(target Target) (*model.NodeMetric, error) {
+	resp, err := s.client.Get(target.URL)
+	if err != nil {
+		return nil, fmt.Errorf("get metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get metrics: unexpected status %s", resp.Status)
+	}
+	samples, err := Parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return s.convert(target, samples, time.Now()), nil
+}
+
+func (s *Scraper) convert(target Target, samples Samples, now time.Time) *model.NodeMetric {
+	target = withDefaults(target)
+	curr := readCounters(target, samples, now)
+
+	s.lock.Lock()
+	prev, ok := s.prev[target.NodeID]
+	s.prev[target.NodeID] = curr
+	s.lock.Unlock()
+	if !ok {
+		return nil
+	}
+
+	metric := &model.NodeMetric{
+		Timestamp: now,
+		NodeInfo:  model.NodeInfo{ID: target.NodeID},
+	}
+	if u, err := url.Parse(target.URL); err == nil {
+		metric.NodeInfo.Address = u.Hostname()
+	}
+	seconds := now.Sub(prev.timestamp).Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+	if curr.cpuOK && prev.cpuOK {
+		metric.CPU = model.CPU{
+			Valid:     true,
+			User:      delta(curr.cpuUser, prev.cpuUser, userHZ),
+			System:    delta(curr.cpuSystem, prev.cpuSystem, userHZ),
+			Idle:      delta(curr.cpuIdle, prev.cpuIdle, userHZ),
+			Nice:      delta(curr.cpuNice, prev.cpuNice, userHZ),
+			IOWait:    delta(curr.cpuIOWait, prev.cpuIOWait, userHZ),
+			IRQ:       delta(curr.cpuIRQ, prev.cpuIRQ, userHZ),
+			SoftIRQ:   delta(curr.cpuSoft, prev.cpuSoft, userHZ),
+			Steal:     delta(curr.cpuSteal, prev.cpuSteal, userHZ),
+			Guest:     delta(curr.cpuGuest, prev.cpuGuest, userHZ),
+			GuestNice: delta(curr.cpuGNice, prev.cpuGNice, userHZ),
+		}
+	}
+	total, okTotal := samples.Find("node_memory_MemTotal_bytes", nil)
+	free, okFree := samples.Find("node_memory_MemFree_bytes", nil)
+	if okTotal && okFree {
+		cached, _ := samples.Find("node_memory_Cached_bytes", nil)
+		buffers, _ := samples.Find("node_memory_Buffers_bytes", nil)
+		available, _ := samples.Find("node_memory_MemAvailable_bytes", nil)
+		swapTotal, _ := samples.Find("node_memory_SwapTotal_bytes", nil)
+		swapFree, _ := samples.Find("node_memory_SwapFree_bytes", nil)
+		metric.Memory = model.Memory{
+			Valid:     true,
+			Total:     uint64(total),
+			Used:      sub(total, free+cached+buffers),
+			Cached:    uint64(cached),
+			Free:      uint64(free),
+			Available: uint64(available),
+			Buffers:   uint64(buffers),
+			SwapTotal: uint64(swapTotal),
+			SwapUsed:  sub(swapTotal, swapFree),
+		}
+		if curr.swapOK && prev.swapOK {
+			metric.Memory.SwapIn = delta(curr.swapIn, prev.swapIn, 1/seconds)
+			metric.Memory.SwapOut = delta(curr.swapOut, prev.swapOut, 1/seconds)
+		}
+	}
+	mount := map[string]string{"mountpoint": target.Mount}
+	size, okSize := samples.Find("node_filesystem_size_bytes", mount)
+	fsFree, okFsFree := samples.Find("node_filesystem_free_bytes", mount)
+	// 使用量不依赖块设备的 I/O 计数器，找不到设备时只缺少 ReadTimes 等 I/O 数据
+	diskOK := curr.diskOK && prev.diskOK && curr.device == prev.device
+	if okSize && okFsFree {
+		metric.Disk = model.Disk{
+			Valid: true,
+			Size:  uint64(size),
+			Used:  sub(size, fsFree),
+			Free:  uint64(fsFree),
+		}
+		if diskOK {
+			metric.Disk.ReadTimes = delta(curr.reads, prev.reads, 1/seconds)
+			metric.Disk.WriteTimes = delta(curr.writes, prev.writes, 1/seconds)
+		}
+		avail, _ := samples.Find("node_filesystem_avail_bytes", mount)
+		readOnly, _ := samples.Find("node_filesystem_readonly", mount)
+		metric.Disk.Available = uint64(avail)
+		metric.Disk.ReadOnly = readOnly == 1
+		if fs := samples.All("node_filesystem_size_bytes", mount); len(fs) > 0 {
+			metric.Disk.FsType = fs[0].Labels["fstype"]
+		}
+		files, okFiles := samples.Find("node_filesystem_files", mount)
+		filesFree, okFilesFree := samples.Find("node_filesystem_files_free", mount)
+		if okFiles && okFilesFree {
+			metric.Disk.Inodes = uint64(files)
+			metric.Disk.InodesUsed = sub(files, filesFree)
+			metric.Disk.InodesFree = uint64(filesFree)
+		}
+	}
+	if metric.Disk.Valid && diskOK && curr.ioOK && prev.ioOK {
+		metric.Disk.Devices = []model.DiskIO{diskIO(curr.device, prev, curr, seconds)}
+	}
+	if curr.netOK && prev.netOK && curr.iface == prev.iface {
+		metric.Network = model.Network{
+			Valid:     true,
+			RxBytes:   delta(curr.rxBytes, prev.rxBytes, 1/seconds),
+			TxBytes:   delta(curr.txBytes, prev.txBytes, 1/seconds),
+			RxPackets: delta(curr.rxPackets, prev.rxPackets, 1/seconds),
+			TxPackets: delta(curr.txPackets, prev.txPackets, 1/seconds),
+			RxErrors:  delta(curr.rxErrors, prev.rxErrors, 1/seconds),
+			TxErrors:  delta(curr.txErrors, prev.txErrors, 1/seconds),
+			RxDropped: delta(curr.rxDropped, prev.rxDropped, 1/seconds),
+			TxDropped: delta(curr.txDropped, prev.txDropped, 1/seconds),
+		}
+		// node_network_speed_bytes 为每秒字节数
+		if speed, ok := samples.Find("node_network_speed_bytes", map[string]string{"device": curr.iface}); ok && speed > 0 {
+			metric.Network.SpeedMbps = uint64(speed * 8 / 1000 / 1000)
+		}
+	}
+	metric.Sockets = sockets(samples)
+	load1, okLoad1 := samples.Find("node_load1", nil)
+	load5, okLoad5 := samples.Find("node_load5", nil)
+	load15, okLoad15 := samples.Find("node_load15", nil)
+	if okLoad1 && okLoad5 && okLoad15 {
+		running, _ := samples.Find("node_procs_running", nil)
+		blocked, _ := samples.Find("node_procs_blocked", nil)
+		metric.Load = model.Load{
+			Valid:    true,
+			Load1:    load1,
+			Load5:    load5,
+			Load15:   load15,
+			Runnable: uint64(running),
+			Blocked:  uint64(blocked),
+			CPUs:     len(samples.All("node_cpu_seconds_total", map[string]string{"mode": "idle"})),
+		}
+	}
+	return metric
+}
+
+func readCounters(target Target, samples Samples, now time.Time) counters {
+	c := counters{timestamp: now}
+	var okUser, okSystem, okIdle bool
+	c.cpuUser, okUser = samples.Sum("node_cpu_seconds_total", map[string]string{"mode": "user"})
+	c.cpuSystem, okSystem = samples.Sum("node_cpu_seconds_total", map[string]string{"mode": "system"})
+	c.cpuIdle, okIdle = samples.Sum("node_cpu_seconds_total", map[string]string{"mode": "idle"})
+	c.cpuOK = okUser && okSystem && okIdle
+	// 其他状态在部分平台上不存在，缺少时按 0 处理
+	c.cpuNice, _ = samples.Sum("node_cpu_seconds_total", map[string]string{"mode": "nice"})
+	c.cpuIOWait, _ = samples.Sum("node_cpu_seconds_total", map[string]string{"mode": "iowait"})
+	c.cpuIRQ, _ = samples.Sum("node_cpu_seconds_total", map[string]string{"mode": "irq"})
+	c.cpuSoft, _ = samples.Sum("node_cpu_seconds_total", map[string]string{"mode": "softirq"})
+	c.cpuSteal, _ = samples.Sum("node_cpu_seconds_total", map[string]string{"mode": "steal"})
+	c.cpuGuest, _ = samples.Sum("node_cpu_guest_seconds_total", map[string]string{"mode": "user"})
+	c.cpuGNice, _ = samples.Sum("node_cpu_guest_seconds_total", map[string]string{"mode": "nice"})
+
+	c.device = target.Device
+	if c.device == "" {
+		c.device = diskDevice(samples, target.Mount)
+	}
+	device := map[string]string{"device": c.device}
+	var okReads, okWrites bool
+	c.reads, okReads = samples.Find("node_disk_reads_completed_total", device)
+	c.writes, okWrites = samples.Find("node_disk_writes_completed_total", device)
+	c.diskOK = okReads && okWrites
+	var okReadBytes, okWriteBytes, okReadTime, okWriteTime, okIOTime, okIOWeighted bool
+	c.readBytes, okReadBytes = samples.Find("node_disk_read_bytes_total", device)
+	c.writeBytes, okWriteBytes = samples.Find("node_disk_written_bytes_total", device)
+	c.readTime, okReadTime = samples.Find("node_disk_read_time_seconds_total", device)
+	c.writeTime, okWriteTime = samples.Find("node_disk_write_time_seconds_total", device)
+	c.ioTime, okIOTime = samples.Find("node_disk_io_time_seconds_total", device)
+	c.ioWeighted, okIOWeighted = samples.Find("node_disk_io_time_weighted_seconds_total", device)
+	c.ioOK = okReadBytes && okWriteBytes && okReadTime && okWriteTime && okIOTime && okIOWeighted
+
+	c.iface = target.Interface
+	if c.iface == "" {
+		c.iface = defaultInterface(samples)
+	}
+	iface := map[string]string{"device": c.iface}
+	var okRx, okTx bool
+	c.rxBytes, okRx = samples.Find("node_network_receive_bytes_total", iface)
+	c.txBytes, okTx = samples.Find("node_network_transmit_bytes_total", iface)
+	c.netOK = okRx && okTx
+	// 包数、错误和丢包数缺少时按 0 处理
+	c.rxPackets, _ = samples.Find("node_network_receive_packets_total", iface)
+	c.txPackets, _ = samples.Find("node_network_transmit_packets_total", iface)
+	c.rxErrors, _ = samples.Find("node_network_receive_errs_total", iface)
+	c.txErrors, _ = samples.Find("node_network_transmit_errs_total", iface)
+	c.rxDropped, _ = samples.Find("node_network_receive_drop_total", iface)
+	c.txDropped, _ = samples.Find("node_network_transmit_drop_total", iface)
+
+	var okIn, okOut bool
+	c.swapIn, okIn = samples.Find("node_vmstat_pswpin", nil)
+	c.swapOut, okOut = samples.Find("node_vmstat_pswpout", nil)
+	c.swapOK = okIn && okOut
+	return c
+}
+
+// diskIO 计算方式与 collector 中读取 /proc/diskstats 的方式相同
+func diskIO(device string, prev, curr counters, seconds float64) model.DiskIO {
+	reads := float64(delta(curr.reads, prev.reads, 1))
+	writes := float64(delta(curr.writes, prev.writes, 1))
+	res := model.DiskIO{
+		Device:     device,
+		ReadIOPS:   reads / seconds,
+		WriteIOPS:  writes / seconds,
+		ReadBytes:  delta(curr.readBytes, prev.readBytes, 1/seconds),
+		WriteBytes: delta(curr.writeBytes, prev.writeBytes, 1/seconds),
+		QueueDepth: float64(delta(curr.ioWeighted, prev.ioWeighted, 1000)) / (seconds * 1000),
+		Util:       float64(delta(curr.ioTime, prev.ioTime, 1000)) / (seconds * 1000) * 100.0,
+	}
+	if reads+writes > 0 {
+		ioMs := delta(curr.readTime, prev.readTime, 1000) + delta(curr.writeTime, prev.writeTime, 1000)
+		res.AwaitMs = float64(ioMs) / (reads + writes)
+	}
+	if res.Util > 100 {
+		res.Util = 100
+	}
+	return res
+}
+
+// sockets node_exporter 不提供临时端口的使用情况，TCP 状态需要开启 tcpstat collector
+func sockets(samples Samples) model.Sockets {
+	res := model.Sockets{TCPStates: map[string]uint64{}}
+	for _, sample := range samples.All("node_tcp_connection_states", nil) {
+		res.TCPStates[strings.ToUpper(sample.Labels["state"])] = uint64(sample.Value)
+		res.Valid = true
+	}
+	if _, ok := res.TCPStates["TIME_WAIT"]; !ok {
+		if tw, ok := samples.Find("node_sockstat_TCP_tw", nil); ok {
+			res.TCPStates["TIME_WAIT"] = uint64(tw)
+			res.Valid = true
+		}
+	}
+	count, okCount := samples.Find("node_nf_conntrack_entries", nil)
+	limit, okLimit := samples.Find("node_nf_conntrack_entries_limit", nil)
+	if okCount && okLimit {
+		res.ConntrackCount, res.ConntrackMax = uint64(count), uint64(limit)
+		res.Valid = true
+	}
+	if !res.Valid {
+		return model.Sockets{}
+	}
+	return res
+}
+
+// diskDevice 根据 node_filesystem_size_bytes 的 device 标签找到挂载点所在的块设备
+// node_exporter 默认不导出分区的 I/O 数据，分区不存在时使用所在的磁盘，例如 /dev/nvme0n1p1 对应 nvme0n1，
+// /dev/mapper/vg-root 通过 node_disk_device_mapper_info 找到 dm-0，都找不到时返回空字符串
+func diskDevice(samples Samples, mount string) string {
+	fs := samples.All("node_filesystem_size_bytes", map[string]string{"mountpoint": mount})
+	if len(fs) == 0 || !strings.HasPrefix(fs[0].Labels["device"], "/dev/") {
+		return ""
+	}
+	path := fs[0].Labels["device"]
+	if strings.HasPrefix(path, "/dev/mapper/") {
+		dm := samples.All("node_disk_device_mapper_info", map[string]string{"name": strings.TrimPrefix(path, "/dev/mapper/")})
+		if len(dm) == 0 {
+			return ""
+		}
+		return dm[0].Labels["device"]
+	}
+	name := path[strings.LastIndex(path, "/")+1:]
+	for _, candidate := range []string{name, parentDisk(name)} {
+		if _, ok := samples.Find("node_disk_reads_completed_total", map[string]string{"device": candidate}); ok {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// parentDisk 去掉分区号，sda1 对应 sda，nvme0n1p1 和 mmcblk0p1 对应 nvme0n1 和 mmcblk0
+func parentDisk(name string) string {
+	trimmed := strings.TrimRight(name, "0123456789")
+	if trimmed == name {
+		return name
+	}
+	if n := len(trimmed); n >= 2 && trimmed[n-1] == 'p' && trimmed[n-2] >= '0' && trimmed[n-2] <= '9' {
+		return trimmed[:n-1]
+	}
+	if strings.HasPrefix(name, "nvme") || strings.HasPrefix(name, "mmcblk") {
+		// nvme0n1 本身不是分区
+		return name
+	}
+	return trimmed
+}
+
+// defaultInterface 返回默认路由所在的网卡，与 agent 读取 /proc/net/route 的方式一致
+// node_network_route_info 需要开启 node_exporter 的 network_route collector，没有时使用除 lo 外接收字节数最多的网卡
+func defaultInterface(samples Samples) string {
+	var name string
+	best := -1.0
+	for _, route := range samples.All("node_network_route_info", nil) {
+		switch route.Labels["dest"] {
+		case "0.0.0.0", "0.0.0.0/0", "default":
+		default:
+			continue
+		}
+		priority, err := strconv.ParseFloat(route.Labels["priority"], 64)
+		if err != nil {
+			priority = 0
+		}
+		if name == "" || priority < best {
+			name, best = route.Labels["device"], priority
+		}
+	}
+	if name != "" {
+		return name
+	}
+	for _, sample := range samples.All("node_network_receive_bytes_total", nil) {
+		device := sample.Labels["device"]
+		if device == "lo" || device == "" {
+			continue
+		}
+		if sample.Value > best || (sample.Value == best && device < name) {
+			name, best = device, sample.Value
+		}
+	}
+	return name
+}
+
+func withDefaults(target Target) Target {
+	if target.Mount == "" {
+		target.Mount = "/"
+	}
+	return target
+}
+
+// delta 计数器被重置（节点重启）时返回 0
+func delta(curr, prev, scale float64) uint64 {
+	if curr < prev {
+		return 0
+	}
+	return uint64((curr - prev) * scale)
+}
+
+func sub(a, b float64) uint64 {
+	if a < b {
+		return 0
+	}
+	return uint64(a - b)
+}
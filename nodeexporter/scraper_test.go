@@ -0,0 +1,170 @@
+package nodeexporter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"systeminfoagent/model"
+)
+
+// fakeExporter 每次请求时累计值增加固定的量
+func fakeExporter(t *testing.T) *httptest.Server {
+	var n int64
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := atomic.AddInt64(&n, 1)
+		fmt.Fprintf(w, "node_cpu_seconds_total{cpu=\"0\",mode=\"user\"} %d\n", 10*i)
+		fmt.Fprintf(w, "node_cpu_seconds_total{cpu=\"0\",mode=\"system\"} %d\n", 5*i)
+		fmt.Fprintf(w, "node_cpu_seconds_total{cpu=\"0\",mode=\"idle\"} %d\n", 100*i)
+		fmt.Fprintln(w, "node_memory_MemTotal_bytes 1000")
+		fmt.Fprintln(w, "node_memory_MemFree_bytes 400")
+		fmt.Fprintln(w, "node_memory_Cached_bytes 100")
+		fmt.Fprintln(w, "node_memory_Buffers_bytes 100")
+		fmt.Fprintln(w, `node_filesystem_size_bytes{device="/dev/sda1",mountpoint="/"} 1000`)
+		fmt.Fprintln(w, `node_filesystem_free_bytes{mountpoint="/"} 250`)
+		fmt.Fprintf(w, "node_disk_reads_completed_total{device=\"sda\"} %d\n", 50*i)
+		fmt.Fprintf(w, "node_disk_writes_completed_total{device=\"sda\"} %d\n", 20*i)
+		fmt.Fprintf(w, "node_network_receive_bytes_total{device=\"enp0s5\"} %d\n", 1000*i)
+		fmt.Fprintf(w, "node_network_transmit_bytes_total{device=\"enp0s5\"} %d\n", 500*i)
+	}))
+}
+
+func TestScrape(t *testing.T) {
+	server := fakeExporter(t)
+	defer server.Close()
+	scraper := NewScraper(nil, 0, nil)
+	target := Target{NodeID: "node1", URL: server.URL + "/metrics"}
+
+	metric, err := scraper.Scrape(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metric != nil {
+		t.Fatalf("first scrape returned %+v, want nil", metric)
+	}
+	metric, err = scraper.Scrape(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metric.NodeInfo.ID != "node1" || metric.NodeInfo.Address != "127.0.0.1" {
+		t.Errorf("node info = %+v", metric.NodeInfo)
+	}
+	if !metric.CPU.Valid || metric.CPU.User != 10*userHZ || metric.CPU.System != 5*userHZ || metric.CPU.Idle != 100*userHZ {
+		t.Errorf("cpu = %+v", metric.CPU)
+	}
+	if !metric.Memory.Valid || metric.Memory.Total != 1000 || metric.Memory.Used != 400 || metric.Memory.Free != 400 {
+		t.Errorf("memory = %+v", metric.Memory)
+	}
+	if !metric.Disk.Valid || metric.Disk.Size != 1000 || metric.Disk.Used != 750 || metric.Disk.ReadTimes == 0 {
+		t.Errorf("disk = %+v", metric.Disk)
+	}
+	if !metric.Network.Valid || metric.Network.RxBytes == 0 {
+		t.Errorf("network = %+v", metric.Network)
+	}
+}
+
+func TestScrapeBadStatus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	if _, err := NewScraper(nil, 0, nil).Scrape(Target{NodeID: "n", URL: server.URL}); err == nil {
+		t.Error("expected an error for 404")
+	}
+}
+
+func TestDelta(t *testing.T) {
+	if d := delta(5, 10, 1); d != 0 {
+		t.Errorf("delta after counter reset = %d, want 0", d)
+	}
+	if d := delta(10, 4, 2); d != 12 {
+		t.Errorf("delta = %d, want 12", d)
+	}
+}
+
+func parse(t *testing.T, text string) Samples {
+	samples, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+func TestDiskDevice(t *testing.T) {
+	cases := []struct {
+		name    string
+		metrics string
+		want    string
+	}{
+		{"partition", `node_filesystem_size_bytes{device="/dev/sda1",mountpoint="/"} 1
+node_disk_reads_completed_total{device="sda"} 1`, "sda"},
+		{"nvme partition", `node_filesystem_size_bytes{device="/dev/nvme0n1p2",mountpoint="/"} 1
+node_disk_reads_completed_total{device="nvme0n1"} 1`, "nvme0n1"},
+		{"partition exported", `node_filesystem_size_bytes{device="/dev/vda1",mountpoint="/"} 1
+node_disk_reads_completed_total{device="vda"} 1
+node_disk_reads_completed_total{device="vda1"} 1`, "vda1"},
+		{"device mapper", `node_filesystem_size_bytes{device="/dev/mapper/vg-root",mountpoint="/"} 1
+node_disk_device_mapper_info{device="dm-0",name="vg-root"} 1
+node_disk_reads_completed_total{device="dm-0"} 1`, "dm-0"},
+		{"overlay", `node_filesystem_size_bytes{device="overlay",mountpoint="/"} 1`, ""},
+		{"not exported", `node_filesystem_size_bytes{device="/dev/sdb1",mountpoint="/"} 1
+node_disk_reads_completed_total{device="sda"} 1`, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := diskDevice(parse(t, c.metrics), "/"); got != c.want {
+				t.Errorf("diskDevice = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDefaultInterface(t *testing.T) {
+	traffic := `node_network_receive_bytes_total{device="lo"} 1e9
+node_network_receive_bytes_total{device="eth0"} 1e6
+node_network_receive_bytes_total{device="eth1"} 1e3
+`
+	if got := defaultInterface(parse(t, traffic)); got != "eth0" {
+		t.Errorf("without routes: %q, want eth0", got)
+	}
+	routes := traffic + `node_network_route_info{device="eth0",dest="10.0.0.0/8",priority="0"} 1
+node_network_route_info{device="eth0",dest="0.0.0.0",priority="200"} 1
+node_network_route_info{device="eth1",dest="0.0.0.0",priority="100"} 1
+`
+	if got := defaultInterface(parse(t, routes)); got != "eth1" {
+		t.Errorf("with routes: %q, want eth1", got)
+	}
+}
+
+// 找不到挂载点所在的块设备时，磁盘使用量仍然有效，只是没有 I/O 数据
+func TestDiskValidWithoutIOCounters(t *testing.T) {
+	samples := parse(t, `node_filesystem_size_bytes{device="overlay",mountpoint="/"} 1000
+node_filesystem_free_bytes{device="overlay",mountpoint="/"} 250
+`)
+	scraper := NewScraper(nil, 0, nil)
+	target := Target{NodeID: "n", URL: "http://127.0.0.1:9100/metrics"}
+	now := time.Now()
+	scraper.convert(target, samples, now)
+	metric := scraper.convert(target, samples, now.Add(time.Second))
+	if !metric.Disk.Valid || metric.Disk.Used != 750 || len(metric.Disk.Devices) != 0 {
+		t.Errorf("disk = %+v", metric.Disk)
+	}
+}
+
+func TestScrapeAllPrune(t *testing.T) {
+	server := fakeExporter(t)
+	defer server.Close()
+	targets := []Target{{NodeID: "n1", URL: server.URL}, {NodeID: "n2", URL: server.URL}}
+	scraper := NewScraper(nil, 0, func() []Target { return targets })
+	out := make(chan *model.NodeMetric, 10)
+	scraper.ScrapeAll(out)
+	targets = targets[:1]
+	scraper.ScrapeAll(out)
+	scraper.lock.Lock()
+	defer scraper.lock.Unlock()
+	if _, ok := scraper.prev["n2"]; ok || len(scraper.prev) != 1 {
+		t.Errorf("prev = %v, want only n1", scraper.prev)
+	}
+}
@@ -0,0 +1,180 @@
+// Package policy 根据 pod 的 annotation、namespace 和 label 选择打分时使用的配置
+// master 和调度插件使用同一套规则，保证两者的打分结果一致
+package policy
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"systeminfoagent/processor"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationProfile pod 使用 master 配置中的哪个 profile 打分
+	AnnotationProfile = "scheduler.systeminfoagent.io/profile"
+	// AnnotationWeights pod 直接指定各个 processor 的权重，例如 "cpu=200,memory=50"
+	// 与 profile 同时存在时，在 profile 的基础上覆盖
+	// 设置了权重的 pod 总是使用 processor.ScoringV2 打分，否则权重不会改变节点之间的排序
+	AnnotationWeights = "scheduler.systeminfoagent.io/weights"
+)
+
+// Profile 一组命名的 processor 权重以及阈值等参数
+type Profile = processor.Override
+
+// Rule 按 namespace 和 label 为 pod 选择 profile，规则按配置中的顺序匹配，第一个匹配的生效
+type Rule struct {
+	Name string `json:"name"`
+	// Namespaces 为空时匹配所有 namespace
+	Namespaces []string `json:"namespaces"`
+	// MatchLabels pod 必须包含全部的 label
+	MatchLabels map[string]string `json:"match_labels"`
+	Profile     string            `json:"profile"`
+}
+
+func (r *Rule) Match(pod *v1.Pod) bool {
+	if len(r.Namespaces) > 0 {
+		found := false
+		for _, ns := range r.Namespaces {
+			if ns == pod.Namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for k, v := range r.MatchLabels {
+		if value, ok := pod.Labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Select 为 pod 选择打分时使用的配置，优先级从低到高为：
+// 全局配置、第一个匹配的规则指定的 profile、annotation 指定的 profile、annotation 指定的权重
+// 任何无法识别的 profile 或 annotation 都会被忽略
+func Select(pod *v1.Pod, base *processor.Config, profiles map[string]Profile, rules []Rule) *processor.Config {
+	if pod == nil {
+		return base
+	}
+	var profileName, ruleName string
+	for i := range rules {
+		if rules[i].Match(pod) {
+			profileName, ruleName = rules[i].Profile, rules[i].Name
+			break
+		}
+	}
+	if name, ok := pod.Annotations[AnnotationProfile]; ok {
+		if _, ok := profiles[name]; ok {
+			profileName, ruleName = name, ""
+		} else {
+			log.Printf("[warn] pod %s: unknown scoring profile %q, ignore annotation", PodName(pod), name)
+		}
+	}
+
+	override := processor.Override{
+		Weights: map[string]int32{},
+		Params:  map[string]map[string]float64{},
+	}
+	var names []string
+	if profile, ok := profiles[profileName]; ok {
+		for k, v := range profile.Weights {
+			override.Weights[k] = v
+		}
+		for k, v := range profile.Params {
+			override.Params[k] = v
+		}
+		override.Scoring = profile.Scoring
+		names = append(names, profileName)
+	}
+	if value, ok := pod.Annotations[AnnotationWeights]; ok {
+		podWeights, err := ParseWeights(value)
+		if err == nil {
+			err = processor.ValidateWeights(podWeights)
+		}
+		if err != nil {
+			log.Printf("[warn] pod %s: ignore annotation %s: %v", PodName(pod), AnnotationWeights, err)
+		} else {
+			for k, v := range podWeights {
+				override.Weights[k] = v
+			}
+			override.Scoring = processor.ScoringV2
+			names = append(names, "annotation")
+		}
+	}
+	if len(names) == 0 {
+		return base
+	}
+	config, err := base.WithOverride(strings.Join(names, "+"), ruleName, override)
+	if err != nil {
+		log.Printf("[warn] pod %s: %v, use global weights", PodName(pod), err)
+		return base
+	}
+	return config
+}
+
+// ParseWeights 解析 "cpu=200,memory=50" 形式的权重
+func ParseWeights(value string) (map[string]int32, error) {
+	weights := map[string]int32{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid weight %q", item)
+		}
+		w, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight %q: %v", item, err)
+		}
+		weights[strings.TrimSpace(kv[0])] = int32(w)
+	}
+	return weights, nil
+}
+
+// PodName 返回 namespace/name 形式的 pod 名称，用于日志
+func PodName(pod *v1.Pod) string {
+	if pod == nil {
+		return "<nil>"
+	}
+	return pod.Namespace + "/" + pod.Name
+}
+
+// Policy 选择配置所需要的全部内容，可以通过 master 的 /api/v1/policy 获取
+type Policy struct {
+	Profiles map[string]Profile `json:"profiles"`
+	Rules    []Rule             `json:"rules"`
+}
+
+// Validate 检查 profile 是否合法，规则引用的 profile 是否存在
+func (p *Policy) Validate() error {
+	for name, profile := range p.Profiles {
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("profile %s: %v", name, err)
+		}
+	}
+	for i, rule := range p.Rules {
+		if _, ok := p.Profiles[rule.Profile]; !ok {
+			return fmt.Errorf("rule %d (%s): unknown profile %q", i, rule.Name, rule.Profile)
+		}
+	}
+	return nil
+}
+
+// Select 使用 p 中的 profile 和规则为 pod 选择配置
+func (p *Policy) Select(pod *v1.Pod, base *processor.Config) *processor.Config {
+	return Select(pod, base, p.Profiles, p.Rules)
+}
+
+// Snapshot 当前的 processor 配置以及选择 profile 的规则
+type Snapshot struct {
+	Config *processor.ConfigStatus `json:"config"`
+	Policy Policy                  `json:"policy"`
+}
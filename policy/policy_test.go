@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"testing"
+
+	"systeminfoagent/model"
+	"systeminfoagent/processor"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rankNodes cpu-idle 的 CPU 空闲但内存紧张，memory-free 相反，返回得分最高的节点
+func rankNodes(config *processor.Config) string {
+	nodes := []*model.NodeFullMetric{
+		{NodeInfo: model.NodeInfo{ID: "cpu-idle"}, RawMetric: model.NodeMetric{
+			CPU:    model.CPU{Valid: true, User: 5, System: 5, Idle: 90},
+			Memory: model.Memory{Valid: true, Total: 100, Free: 20},
+		}},
+		{NodeInfo: model.NodeInfo{ID: "memory-free"}, RawMetric: model.NodeMetric{
+			CPU:    model.CPU{Valid: true, User: 35, System: 35, Idle: 30},
+			Memory: model.Memory{Valid: true, Total: 100, Free: 85},
+		}},
+	}
+	var best string
+	var max float64
+	for _, nfm := range nodes {
+		if score, _ := processor.NewScoreProcessor().Score(nfm, config, processor.PodRequest{}); score > max {
+			best, max = nfm.NodeInfo.ID, score
+		}
+	}
+	return best
+}
+
+// cpuAndMemoryOnly 只启用 cpu 和 memory 的全局配置
+func cpuAndMemoryOnly(t *testing.T) *processor.Config {
+	config, err := processor.Current().WithOverride("", "", processor.Override{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for processorType, pc := range config.Processors {
+		pc.Enabled = processorType == processor.TCPUPROCESSOR || processorType == processor.TMEMORYPROCESSOR
+		config.Processors[processorType] = pc
+	}
+	return config
+}
+
+func TestAnnotationWeightsRankNodes(t *testing.T) {
+	base := cpuAndMemoryOnly(t)
+	pod := func(weights string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "web",
+			Annotations: map[string]string{AnnotationWeights: weights},
+		}}
+	}
+	// 全局配置使用 ScoringV1，annotation 无效时 memory-free 排在前面
+	if got := rankNodes(Select(pod("cpu"), base, nil, nil)); got != "memory-free" {
+		t.Errorf("invalid annotation: best node %s, want memory-free", got)
+	}
+	if got := rankNodes(Select(pod("cpu=300,memory=50"), base, nil, nil)); got != "cpu-idle" {
+		t.Errorf("cpu-heavy annotation: best node %s, want cpu-idle", got)
+	}
+	if got := rankNodes(Select(pod("cpu=50,memory=300"), base, nil, nil)); got != "memory-free" {
+		t.Errorf("memory-heavy annotation: best node %s, want memory-free", got)
+	}
+}
@@ -0,0 +1,443 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// MaxExtraWeight extraWeight 的上限，即默认权重的 10 倍
+const MaxExtraWeight int32 = 1000
+
+const (
+	// ParamMaxRxPerSecond network processor 认为网卡满载时的每秒接收字节数，为 0 时不考虑接收
+	ParamMaxRxPerSecond = "max_rx_per_second"
+	// ParamMaxTxPerSecond network processor 认为网卡满载时的每秒发送字节数，为 0 时不考虑发送
+	ParamMaxTxPerSecond = "max_tx_per_second"
+	// ParamUseLinkSpeed 不为 0 时 network processor 优先使用网卡的协商速率作为满载值
+	ParamUseLinkSpeed = "use_link_speed"
+	// ParamMaxErrorsPerSecond network processor 每秒的错误和丢包数达到该值时分数为 0，为 0 时不考虑错误和丢包
+	ParamMaxErrorsPerSecond = "max_errors_per_second"
+	// ParamMaxCoreStdDev cpu processor 各个 CPU 使用率的标准差达到该值时分数为 0，为 0 时不考虑单核过热
+	ParamMaxCoreStdDev = "max_core_stddev"
+	// ParamMinFreePercent memory processor 要求放置 pod 之后至少剩余的内存百分比
+	ParamMinFreePercent = "min_free_percent"
+	// ParamMaxSwapPagesPerSecond memory processor 在 available 模式下，每秒换入换出的页数达到该值时分数为 0
+	ParamMaxSwapPagesPerSecond = "max_swap_pages_per_second"
+	// ParamMinFreeBytes disk processor 要求放置 pod 之后至少剩余的磁盘字节数
+	ParamMinFreeBytes = "min_free_bytes"
+	// ParamMaxLoadPerCore load processor 认为节点满载时每个 CPU 上的 1 分钟平均负载
+	ParamMaxLoadPerCore = "max_load_per_core"
+	// ParamMaxPressurePercent pressure processor 认为节点满载时的 PSI some avg10 百分比
+	ParamMaxPressurePercent = "max_pressure_percent"
+	// ParamMaxAwaitMs diskio processor 认为磁盘满载时每次 I/O 的平均耗时，为 0 时只根据 %util 打分
+	ParamMaxAwaitMs = "max_await_ms"
+	// ParamPenaltyStartPercent sockets processor 开始扣分的使用百分比
+	ParamPenaltyStartPercent = "penalty_start_percent"
+)
+
+// ParamMode 选择 processor 的计算方式，取值由各个 processor 定义
+const ParamMode = "mode"
+
+const (
+	// MemoryModeFree 按 MemFree 计算剩余内存，page cache 被视为已使用，为默认值，与 Even 和 Variance 统计的 Free 一致
+	MemoryModeFree float64 = 0
+	// MemoryModeAvailable 按 MemAvailable 计算剩余内存，并根据 swap 的活跃程度扣分
+	MemoryModeAvailable float64 = 1
+)
+
+const (
+	// CPUModeLegacy 只使用 user、system 和 idle 计算空闲比例，为默认值，保持已有部署的打分不变
+	CPUModeLegacy float64 = 0
+	// CPUModeStealBusy 被虚拟化平台抢占的 steal 视为繁忙，iowait 视为空闲
+	CPUModeStealBusy float64 = 1
+	// CPUModeStealIOWaitBusy steal 和 iowait 都视为繁忙
+	CPUModeStealIOWaitBusy float64 = 2
+)
+
+// processorModes 各个 processor 支持的 mode 取值
+var processorModes = map[ProcessorType][]float64{
+	TCPUPROCESSOR:    {CPUModeLegacy, CPUModeStealBusy, CPUModeStealIOWaitBusy},
+	TMEMORYPROCESSOR: {MemoryModeFree, MemoryModeAvailable},
+}
+
+func validMode(t ProcessorType, mode float64) bool {
+	for _, m := range processorModes[t] {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// positiveParams 作为除数的参数，为 0 时 processor 不再参与打分，必须大于 0
+var positiveParams = map[string]bool{
+	ParamMaxLoadPerCore:     true,
+	ParamMaxPressurePercent: true,
+}
+
+// percentParams 百分比参数，取值范围为 [0, 100)
+var percentParams = map[string]bool{
+	ParamMinFreePercent:      true,
+	ParamPenaltyStartPercent: true,
+}
+
+// validateParam 检查参数值的范围，所有参数都不能是 NaN、Inf 或者负数
+func validateParam(t ProcessorType, param string, value float64) error {
+	switch {
+	case math.IsNaN(value) || math.IsInf(value, 0):
+		return fmt.Errorf("processor %s: param %s must be finite", t, param)
+	case param == ParamMode:
+		if !validMode(t, value) {
+			return fmt.Errorf("processor %s: invalid mode %v", t, value)
+		}
+	case value < 0:
+		return fmt.Errorf("processor %s: param %s must not be negative", t, param)
+	case positiveParams[param] && value == 0:
+		return fmt.Errorf("processor %s: param %s must be greater than 0", t, param)
+	case percentParams[param] && value >= 100:
+		return fmt.Errorf("processor %s: param %s must be less than 100", t, param)
+	}
+	return nil
+}
+
+var defaultextraweight int32 = 100
+
+// ScoringVersion 各个 processor 的分数合并为节点分数的方式
+type ScoringVersion int
+
+const (
+	// ScoringV1 各项分数之和除以权重之和，为默认值，保持已有部署的打分不变
+	// 权重只改变分母，所有节点按同一个比例缩放，profile 和 annotation 中的权重不会改变节点之间的排序
+	ScoringV1 ScoringVersion = 1
+	// ScoringV2 各项分数按权重加权平均，权重决定各项分数所占的比例，
+	// 例如 memory=300,cpu=50 时内存剩余多的节点排在前面
+	ScoringV2 ScoringVersion = 2
+)
+
+func (v ScoringVersion) valid() bool {
+	return v == ScoringV1 || v == ScoringV2
+}
+
+var processorNames = map[ProcessorType]string{
+	TCPUPROCESSOR:       "cpu",
+	TMEMORYPROCESSOR:    "memory",
+	TDISKUSAGEPROCESSOR: "disk",
+	TNETWORKPROCESSOR:   "network",
+	TLOADPROCESSOR:      "load",
+	TPRESSUREPROCESSOR:  "pressure",
+	TDISKIOPROCESSOR:    "diskio",
+	TSOCKETPROCESSOR:    "sockets",
+}
+
+func (t ProcessorType) String() string {
+	if name, ok := processorNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", int(t))
+}
+
+// ParseProcessorType 根据名称查找 processor 类型
+func ParseProcessorType(name string) (ProcessorType, bool) {
+	for t, n := range processorNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// ProcessorConfig 单个 processor 的配置
+type ProcessorConfig struct {
+	Enabled     bool
+	ExtraWeight int32
+	Params      map[string]float64
+}
+
+// Config 某一时刻所有 processor 的配置
+// 创建之后不再修改，修改配置时会生成一份新的 Config 并替换掉当前的，
+// 所以一次调度请求中只要使用同一个 *Config，所有节点都是按照同一份配置打分
+type Config struct {
+	Version    uint64
+	Profile    string // 由 WithOverride 派生出来的配置使用的 profile 名称
+	Rule       string // 选中 profile 的规则名称
+	Scoring    ScoringVersion
+	Processors map[ProcessorType]ProcessorConfig
+}
+
+func (c *Config) types() []ProcessorType {
+	res := make([]ProcessorType, 0, len(c.Processors))
+	for t := range c.Processors {
+		res = append(res, t)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+func (c *Config) clone() *Config {
+	res := &Config{
+		Version:    c.Version,
+		Profile:    c.Profile,
+		Rule:       c.Rule,
+		Scoring:    c.Scoring,
+		Processors: make(map[ProcessorType]ProcessorConfig, len(c.Processors)),
+	}
+	for t, pc := range c.Processors {
+		params := make(map[string]float64, len(pc.Params))
+		for k, v := range pc.Params {
+			params[k] = v
+		}
+		pc.Params = params
+		res.Processors[t] = pc
+	}
+	return res
+}
+
+// optInProcessors 后来加入的 processor 默认不启用，避免升级之后已有部署的打分发生变化，
+// 需要时通过 PUT /api/v1/processors 启用
+var optInProcessors = map[ProcessorType]bool{
+	TLOADPROCESSOR:     true,
+	TPRESSUREPROCESSOR: true,
+	TDISKIOPROCESSOR:   true,
+	TSOCKETPROCESSOR:   true,
+}
+
+func defaultConfig() *Config {
+	config := &Config{Version: 1, Scoring: ScoringV1, Processors: map[ProcessorType]ProcessorConfig{}}
+	for t := range processors {
+		config.Processors[t] = ProcessorConfig{Enabled: !optInProcessors[t], ExtraWeight: defaultextraweight}
+	}
+	config.setParam(TCPUPROCESSOR, ParamMode, CPUModeLegacy)
+	config.setParam(TCPUPROCESSOR, ParamMaxCoreStdDev, 0)
+	config.setParam(TMEMORYPROCESSOR, ParamMinFreePercent, 5)
+	config.setParam(TMEMORYPROCESSOR, ParamMode, MemoryModeFree)
+	config.setParam(TMEMORYPROCESSOR, ParamMaxSwapPagesPerSecond, 1000)
+	config.setParam(TDISKUSAGEPROCESSOR, ParamMinFreeBytes, 1<<30)
+	config.setParam(TNETWORKPROCESSOR, ParamMaxRxPerSecond, 1<<20)
+	// 默认只按接收字节数打分，与之前的计算方式相同，发送、协商速率和错误数需要单独开启
+	config.setParam(TNETWORKPROCESSOR, ParamMaxTxPerSecond, 0)
+	config.setParam(TNETWORKPROCESSOR, ParamUseLinkSpeed, 0)
+	config.setParam(TNETWORKPROCESSOR, ParamMaxErrorsPerSecond, 0)
+	config.setParam(TLOADPROCESSOR, ParamMaxLoadPerCore, 2)
+	config.setParam(TPRESSUREPROCESSOR, ParamMaxPressurePercent, 40)
+	config.setParam(TDISKIOPROCESSOR, ParamMaxAwaitMs, 100)
+	config.setParam(TSOCKETPROCESSOR, ParamPenaltyStartPercent, 70)
+	return config
+}
+
+// setParam 只能在 config 被发布之前调用，Params 在 clone 时已经被复制过
+func (c *Config) setParam(t ProcessorType, name string, value float64) {
+	pc := c.Processors[t]
+	if pc.Params == nil {
+		pc.Params = map[string]float64{}
+	}
+	pc.Params[name] = value
+	c.Processors[t] = pc
+}
+
+var (
+	current     atomic.Value // *Config
+	updateMutex sync.Mutex
+)
+
+func init() {
+	current.Store(defaultConfig())
+}
+
+// Current 返回当前生效的配置，调用方不能修改返回值
+func Current() *Config {
+	return current.Load().(*Config)
+}
+
+// Override 在全局配置的基础上修改部分 processor 的权重和参数
+type Override struct {
+	Weights map[string]int32              `json:"weights"`
+	Params  map[string]map[string]float64 `json:"params"` // processor 名称 -> 参数名 -> 值
+	// Scoring 为 0 时，设置了权重的 override 使用 ScoringV2，否则沿用全局配置的合并方式
+	// ScoringV1 下权重不改变节点之间的排序
+	Scoring ScoringVersion `json:"scoring,omitempty"`
+}
+
+// ValidateWeights 检查按名称给出的权重是否合法
+func ValidateWeights(weights map[string]int32) error {
+	for name, w := range weights {
+		if _, ok := ParseProcessorType(name); !ok {
+			return fmt.Errorf("unknown processor %q", name)
+		}
+		if w < 0 || w > MaxExtraWeight {
+			return fmt.Errorf("processor %s: weight must be in [0, %d]", name, MaxExtraWeight)
+		}
+	}
+	return nil
+}
+
+// Validate 检查 override 中的 processor 和参数是否存在，以及参数值是否在合法范围内
+func (o Override) Validate() error {
+	if err := ValidateWeights(o.Weights); err != nil {
+		return err
+	}
+	if o.Scoring != 0 && !o.Scoring.valid() {
+		return fmt.Errorf("unknown scoring version %d", o.Scoring)
+	}
+	defaults := defaultConfig()
+	for name, params := range o.Params {
+		t, ok := ParseProcessorType(name)
+		if !ok {
+			return fmt.Errorf("unknown processor %q", name)
+		}
+		for param, value := range params {
+			if _, ok := defaults.Processors[t].Params[param]; !ok {
+				return fmt.Errorf("processor %s: unknown param %q", name, param)
+			}
+			if err := validateParam(t, param, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WithOverride 派生出一份应用了 override 的配置，版本号与 c 相同
+// profile 和 rule 记录这份配置的来源，会出现在打分的解释中
+// 被全局禁用的 processor 仍然是禁用的
+func (c *Config) WithOverride(profile, rule string, o Override) (*Config, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	res := c.clone()
+	res.Profile = profile
+	res.Rule = rule
+	switch {
+	case o.Scoring != 0:
+		res.Scoring = o.Scoring
+	case len(o.Weights) > 0:
+		res.Scoring = ScoringV2
+	}
+	for name, w := range o.Weights {
+		t, _ := ParseProcessorType(name)
+		pc := res.Processors[t]
+		pc.ExtraWeight = w
+		res.Processors[t] = pc
+	}
+	for name, params := range o.Params {
+		t, _ := ParseProcessorType(name)
+		for param, value := range params {
+			res.setParam(t, param, value)
+		}
+	}
+	return res, nil
+}
+
+// ProcessorStatus processor 当前的配置，用于 API 展示
+type ProcessorStatus struct {
+	Name    string             `json:"name"`
+	Type    int                `json:"type"`
+	Enabled bool               `json:"enabled"`
+	Weight  int32              `json:"weight"`
+	Share   float64            `json:"share"` // 在所有启用的 processor 中 weight 所占的比例
+	Params  map[string]float64 `json:"params,omitempty"`
+}
+
+// ConfigStatus 某个版本配置的展示形式
+type ConfigStatus struct {
+	Version    uint64            `json:"version"`
+	Scoring    ScoringVersion    `json:"scoring"`
+	Processors []ProcessorStatus `json:"processors"`
+}
+
+// SettingsUpdate 对单个 processor 的修改，为 nil 的字段保持不变
+type SettingsUpdate struct {
+	Weight  *int32 `json:"weight"`
+	Enabled *bool  `json:"enabled"`
+}
+
+// UpdateSettings 批量修改 processor 配置，任意一项校验失败则全部不生效
+// 成功时返回新版本的配置，updates 为空时返回当前配置，版本号不变
+func UpdateSettings(updates map[string]SettingsUpdate) (*Config, error) {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+	if len(updates) == 0 {
+		return Current(), nil
+	}
+	next := Current().clone()
+	for name, update := range updates {
+		t, ok := ParseProcessorType(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown processor %q", name)
+		}
+		if update.Weight != nil {
+			if err := ValidateWeights(map[string]int32{name: *update.Weight}); err != nil {
+				return nil, err
+			}
+		}
+		pc := next.Processors[t]
+		if update.Weight != nil {
+			pc.ExtraWeight = *update.Weight
+		}
+		if update.Enabled != nil {
+			pc.Enabled = *update.Enabled
+		}
+		next.Processors[t] = pc
+	}
+	next.Version++
+	current.Store(next)
+	return next, nil
+}
+
+// Status 返回 config 中所有 processor 的配置，按类型排序
+func (c *Config) Status() *ConfigStatus {
+	var total float64
+	for _, pc := range c.Processors {
+		if pc.Enabled {
+			total += float64(pc.ExtraWeight)
+		}
+	}
+	res := &ConfigStatus{Version: c.Version, Scoring: c.Scoring}
+	for _, t := range c.types() {
+		pc := c.Processors[t]
+		status := ProcessorStatus{
+			Name:    t.String(),
+			Type:    int(t),
+			Enabled: pc.Enabled,
+			Weight:  pc.ExtraWeight,
+			Params:  pc.Params,
+		}
+		if status.Enabled && total > 0 {
+			status.Share = float64(status.Weight) / total
+		}
+		res.Processors = append(res.Processors, status)
+	}
+	return res
+}
+
+// Config 将展示形式转换回配置，用于在 master 之外的进程中使用与 master 相同的配置
+func (s *ConfigStatus) Config() (*Config, error) {
+	if s == nil {
+		return nil, fmt.Errorf("empty config")
+	}
+	// 旧版本的 master 不返回 scoring，使用 ScoringV1
+	config := &Config{Version: s.Version, Scoring: s.Scoring, Processors: map[ProcessorType]ProcessorConfig{}}
+	if config.Scoring == 0 {
+		config.Scoring = ScoringV1
+	}
+	if !config.Scoring.valid() {
+		return nil, fmt.Errorf("unknown scoring version %d", s.Scoring)
+	}
+	for _, status := range s.Processors {
+		t, ok := ParseProcessorType(status.Name)
+		if !ok {
+			return nil, fmt.Errorf("unknown processor %q", status.Name)
+		}
+		if err := ValidateWeights(map[string]int32{status.Name: status.Weight}); err != nil {
+			return nil, err
+		}
+		pc := ProcessorConfig{Enabled: status.Enabled, ExtraWeight: status.Weight}
+		config.Processors[t] = pc
+		for k, v := range status.Params {
+			config.setParam(t, k, v)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,246 @@
+package processor
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"systeminfoagent/model"
+)
+
+func resetConfig(t *testing.T) {
+	t.Helper()
+	current.Store(defaultConfig())
+	t.Cleanup(func() { current.Store(defaultConfig()) })
+}
+
+func TestUpdateSettingsNewVersion(t *testing.T) {
+	resetConfig(t)
+	before := Current()
+	weight := int32(300)
+	after, err := UpdateSettings(map[string]SettingsUpdate{"cpu": {Weight: &weight}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Version != before.Version+1 || Current() != after {
+		t.Errorf("version %d -> %d, current %d", before.Version, after.Version, Current().Version)
+	}
+	// 旧的快照不受影响
+	if before.Processors[TCPUPROCESSOR].ExtraWeight != defaultextraweight {
+		t.Errorf("old snapshot was modified: %+v", before.Processors[TCPUPROCESSOR])
+	}
+	if after.Processors[TCPUPROCESSOR].ExtraWeight != 300 {
+		t.Errorf("new weight = %d", after.Processors[TCPUPROCESSOR].ExtraWeight)
+	}
+}
+
+func TestUpdateSettingsEmpty(t *testing.T) {
+	resetConfig(t)
+	before := Current()
+	after, err := UpdateSettings(map[string]SettingsUpdate{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != before || Current().Version != before.Version {
+		t.Errorf("empty update published version %d, want %d", Current().Version, before.Version)
+	}
+}
+
+func TestUpdateSettingsAtomic(t *testing.T) {
+	resetConfig(t)
+	good, bad := int32(10), int32(MaxExtraWeight+1)
+	if _, err := UpdateSettings(map[string]SettingsUpdate{"cpu": {Weight: &good}, "memory": {Weight: &bad}}); err == nil {
+		t.Fatal("expected an error for an out of range weight")
+	}
+	if _, err := UpdateSettings(map[string]SettingsUpdate{"nope": {Weight: &good}}); err == nil {
+		t.Fatal("expected an error for an unknown processor")
+	}
+	if c := Current(); c.Version != 1 || c.Processors[TCPUPROCESSOR].ExtraWeight != defaultextraweight {
+		t.Errorf("failed update was applied: version %d, %+v", c.Version, c.Processors[TCPUPROCESSOR])
+	}
+}
+
+// TestConcurrentSnapshots 在 -race 下运行：打分的同时修改配置，
+// 同一个快照中所有 processor 的权重都来自同一次修改
+func TestConcurrentSnapshots(t *testing.T) {
+	resetConfig(t)
+	nfm := &model.NodeFullMetric{RawMetric: model.NodeMetric{
+		CPU:     model.CPU{Valid: true, User: 10, System: 10, Idle: 80},
+		Memory:  model.Memory{Valid: true, Total: 100, Free: 50},
+		Disk:    model.Disk{Valid: true, Size: 100 << 30, Free: 50 << 30},
+		Network: model.Network{Valid: true},
+	}}
+	sp := NewScoreProcessor()
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := int32(1); i <= 200; i++ {
+			w := i
+			updates := map[string]SettingsUpdate{}
+			for _, name := range processorNames {
+				updates[name] = SettingsUpdate{Weight: &w}
+			}
+			if _, err := UpdateSettings(updates); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		close(stop)
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				config := Current()
+				explanation := sp.Explain(nfm, config, PodRequest{})
+				if explanation.ConfigVersion != config.Version {
+					t.Errorf("explanation version %d, config version %d", explanation.ConfigVersion, config.Version)
+					return
+				}
+				var weight int32 = -1
+				for _, pc := range config.Processors {
+					if weight >= 0 && pc.ExtraWeight != weight {
+						t.Errorf("snapshot %d mixes weights %d and %d", config.Version, weight, pc.ExtraWeight)
+						return
+					}
+					weight = pc.ExtraWeight
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v := Current().Version; v != 201 {
+		t.Errorf("final version = %d, want 201", v)
+	}
+}
+
+func TestOverrideValidate(t *testing.T) {
+	params := func(processor, param string, value float64) Override {
+		return Override{Params: map[string]map[string]float64{processor: {param: value}}}
+	}
+	tests := []struct {
+		name     string
+		override Override
+		ok       bool
+	}{
+		{"empty", Override{}, true},
+		{"weight", Override{Weights: map[string]int32{"disk": 500}}, true},
+		{"weight too large", Override{Weights: map[string]int32{"disk": MaxExtraWeight + 1}}, false},
+		{"negative weight", Override{Weights: map[string]int32{"disk": -1}}, false},
+		{"unknown processor", params("gpu", ParamMode, 0), false},
+		{"unknown param", params("cpu", ParamMinFreeBytes, 1), false},
+		{"mode", params("memory", ParamMode, MemoryModeAvailable), true},
+		{"invalid mode", params("cpu", ParamMode, 3), false},
+		{"nan", params("disk", ParamMinFreeBytes, math.NaN()), false},
+		{"inf", params("network", ParamMaxRxPerSecond, math.Inf(1)), false},
+		{"negative inf", params("network", ParamMaxRxPerSecond, math.Inf(-1)), false},
+		{"negative", params("network", ParamMaxErrorsPerSecond, -1), false},
+		{"zero disables rx", params("network", ParamMaxRxPerSecond, 0), true},
+		{"zero disables stddev", params("cpu", ParamMaxCoreStdDev, 0), true},
+		{"zero load divisor", params("load", ParamMaxLoadPerCore, 0), false},
+		{"zero pressure divisor", params("pressure", ParamMaxPressurePercent, 0), false},
+		{"load", params("load", ParamMaxLoadPerCore, 0.5), true},
+		{"percent", params("memory", ParamMinFreePercent, 99.5), true},
+		{"percent too large", params("memory", ParamMinFreePercent, 100), false},
+		{"penalty start too large", params("sockets", ParamPenaltyStartPercent, 100), false},
+	}
+	for _, tt := range tests {
+		err := tt.override.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestWithOverride(t *testing.T) {
+	base := defaultConfig()
+	o := Override{
+		Weights: map[string]int32{"disk": 300},
+		Params:  map[string]map[string]float64{"load": {ParamMaxLoadPerCore: 4}},
+	}
+	config, err := base.WithOverride("disk-heavy", "analytics", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Version != base.Version || config.Profile != "disk-heavy" || config.Rule != "analytics" {
+		t.Errorf("unexpected config %d %q %q", config.Version, config.Profile, config.Rule)
+	}
+	if config.Processors[TDISKUSAGEPROCESSOR].ExtraWeight != 300 || config.Processors[TLOADPROCESSOR].Params[ParamMaxLoadPerCore] != 4 {
+		t.Errorf("override not applied: %+v", config.Processors)
+	}
+	if base.Processors[TLOADPROCESSOR].Params[ParamMaxLoadPerCore] != 2 {
+		t.Errorf("base config was modified")
+	}
+	if _, err := base.WithOverride("bad", "", Override{Params: map[string]map[string]float64{"load": {ParamMaxLoadPerCore: -1}}}); err == nil {
+		t.Errorf("expected an error for a negative param")
+	}
+}
+
+func TestProfilesRankNodes(t *testing.T) {
+	base := defaultConfig()
+	for t, pc := range base.Processors {
+		pc.Enabled = t == TCPUPROCESSOR || t == TMEMORYPROCESSOR
+		base.Processors[t] = pc
+	}
+	// cpu-idle 的 CPU 空闲但内存紧张，memory-free 相反
+	nodes := []*model.NodeFullMetric{
+		{NodeInfo: model.NodeInfo{ID: "cpu-idle"}, RawMetric: model.NodeMetric{
+			CPU:    model.CPU{Valid: true, User: 5, System: 5, Idle: 90},
+			Memory: model.Memory{Valid: true, Total: 100, Free: 20},
+		}},
+		{NodeInfo: model.NodeInfo{ID: "memory-free"}, RawMetric: model.NodeMetric{
+			CPU:    model.CPU{Valid: true, User: 35, System: 35, Idle: 30},
+			Memory: model.Memory{Valid: true, Total: 100, Free: 85},
+		}},
+	}
+	best := func(config *Config) string {
+		var id string
+		var max float64
+		for _, nfm := range nodes {
+			if score, _ := NewScoreProcessor().Score(nfm, config, PodRequest{}); score > max {
+				id, max = nfm.NodeInfo.ID, score
+			}
+		}
+		return id
+	}
+	profiles := map[string]Override{
+		"cpu-heavy":    {Weights: map[string]int32{"cpu": 300, "memory": 50}},
+		"memory-heavy": {Weights: map[string]int32{"cpu": 50, "memory": 300}},
+	}
+	tests := []struct {
+		profile string
+		scoring ScoringVersion
+		want    string
+	}{
+		// ScoringV1 中权重只改变分母，两个 profile 的排序相同
+		{"cpu-heavy", ScoringV1, "memory-free"},
+		{"memory-heavy", ScoringV1, "memory-free"},
+		{"cpu-heavy", ScoringV2, "cpu-idle"},
+		{"memory-heavy", ScoringV2, "memory-free"},
+		// 没有指定 scoring 时，设置了权重的 profile 使用 ScoringV2
+		{"cpu-heavy", 0, "cpu-idle"},
+		{"memory-heavy", 0, "memory-free"},
+	}
+	for _, tt := range tests {
+		o := profiles[tt.profile]
+		o.Scoring = tt.scoring
+		config, err := base.WithOverride(tt.profile, "", o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := best(config); got != tt.want {
+			t.Errorf("%s with scoring v%d: best node %s, want %s", tt.profile, tt.scoring, got, tt.want)
+		}
+	}
+	if err := (Override{Scoring: 3}).Validate(); err == nil {
+		t.Error("expected an error for an unknown scoring version")
+	}
+}
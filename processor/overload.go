@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"fmt"
+	"systeminfoagent/model"
+)
+
+// Overloaded 判断在释放 freed 的资源并放置 pod 之后，节点是否仍然达不到 config 中的阈值
+// 使用与打分相同的阈值：CPU 空闲时间，内存剩余百分比 min_free_percent，磁盘剩余字节数 min_free_bytes，
+// 每个 CPU 上的负载 max_load_per_core，PSI 百分比 max_pressure_percent，
+// 后两者是打分为 0 的位置，即节点满载；PSI 无法按 pod 的请求估算，释放资源之后仍然按当前值判断
+// 被禁用的 processor、无效的数据以及 pod 没有请求的资源不参与判断
+func Overloaded(nfm *model.NodeFullMetric, config *Config, pod, freed PodRequest) (bool, string) {
+	cpus := cpuCount(nfm.RawMetric)
+	if pc := config.Processors[TCPUPROCESSOR]; pod.MilliCPU > 0 && pc.Enabled && nfm.RawMetric.CPU.Valid && nfm.RawMetric.CPU.Total() > 0 {
+		idle := cpuIdlePercent(nfm.RawMetric.CPU, pc.Params[ParamMode])
+		// 不知道 CPU 个数时无法换算成 millicore，只判断节点是否已经没有空闲时间
+		free := idle
+		if cpus > 0 {
+			free = idle/100.0*float64(cpus)*1000 + float64(freed.MilliCPU) - float64(pod.MilliCPU)
+		} else if freed.MilliCPU >= pod.MilliCPU {
+			free = 1
+		}
+		if free <= 0 {
+			return true, fmt.Sprintf("cpu idle %.2f%% is not enough for %dm after preemption", idle, pod.MilliCPU)
+		}
+	}
+	if pc := config.Processors[TLOADPROCESSOR]; pc.Enabled && nfm.RawMetric.Load.Valid && pc.Params[ParamMaxLoadPerCore] > 0 {
+		load := nfm.RawMetric.Load.LoadPerCore()
+		if cpus > 0 {
+			// 一个 millicore 大约对应 0.001 的负载
+			load += (float64(pod.MilliCPU) - float64(freed.MilliCPU)) / 1000.0 / float64(cpus)
+		}
+		if load >= pc.Params[ParamMaxLoadPerCore] {
+			return true, fmt.Sprintf("load per core %.2f after preemption reaches %.2f", load, pc.Params[ParamMaxLoadPerCore])
+		}
+	}
+	if pc := config.Processors[TPRESSUREPROCESSOR]; pc.Enabled && nfm.RawMetric.Pressure.Valid && pc.Params[ParamMaxPressurePercent] > 0 {
+		if some := nfm.RawMetric.Pressure.MaxSome10(); some >= pc.Params[ParamMaxPressurePercent] {
+			return true, fmt.Sprintf("pressure %.2f%% reaches %.2f%%", some, pc.Params[ParamMaxPressurePercent])
+		}
+	}
+	if pc := config.Processors[TMEMORYPROCESSOR]; pod.Memory > 0 && pc.Enabled && nfm.RawMetric.Memory.Valid && nfm.RawMetric.Memory.Total > 0 {
+		raw := nfm.RawMetric.Memory
+		free := memoryFree(raw, pc) + float64(freed.Memory) - float64(pod.Memory)
+		percent := free / float64(raw.Total) * 100.0
+		if free <= 0 || percent < pc.Params[ParamMinFreePercent] {
+			return true, fmt.Sprintf("memory free %.2f%% after preemption is below %.2f%%", percent, pc.Params[ParamMinFreePercent])
+		}
+	}
+	if pc := config.Processors[TDISKUSAGEPROCESSOR]; pod.EphemeralStorage > 0 && pc.Enabled && nfm.RawMetric.Disk.Valid {
+		raw := nfm.RawMetric.Disk
+		free := float64(raw.Free) + float64(freed.EphemeralStorage) - float64(pod.EphemeralStorage)
+		if free <= 0 || free < pc.Params[ParamMinFreeBytes] {
+			return true, fmt.Sprintf("disk free %.0f bytes after preemption is below %.0f bytes", free, pc.Params[ParamMinFreeBytes])
+		}
+	}
+	return false, ""
+}
+
+// cpuCount 节点的 CPU 个数，优先使用 per-core 数据，都没有时返回 0
+func cpuCount(raw model.NodeMetric) int {
+	if raw.CPU.PerCore != nil && len(raw.CPU.PerCore.Busy) > 0 {
+		return len(raw.CPU.PerCore.Busy)
+	}
+	if raw.Load.Valid {
+		return raw.Load.CPUs
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package processor
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// PodRequest 待调度的 pod 需要的资源，零值表示不考虑 pod
+type PodRequest struct {
+	MilliCPU         int64 `json:"milli_cpu"`
+	Memory           int64 `json:"memory"`
+	EphemeralStorage int64 `json:"ephemeral_storage"`
+}
+
+// NewPodRequest 计算 pod 的资源需求
+// 与 kube-scheduler 一致：普通容器求和，init 容器取最大值，两者再取较大的一个
+// 容器没有设置 requests 时使用 limits
+func NewPodRequest(pod *v1.Pod) PodRequest {
+	var req PodRequest
+	if pod == nil {
+		return req
+	}
+	for _, container := range pod.Spec.Containers {
+		req.Add(containerRequest(container))
+	}
+	for _, container := range pod.Spec.InitContainers {
+		req.max(containerRequest(container))
+	}
+	return req
+}
+
+func containerRequest(container v1.Container) PodRequest {
+	get := func(name v1.ResourceName) resource.Quantity {
+		if q, ok := container.Resources.Requests[name]; ok {
+			return q
+		}
+		return container.Resources.Limits[name]
+	}
+	cpu := get(v1.ResourceCPU)
+	memory := get(v1.ResourceMemory)
+	storage := get(v1.ResourceEphemeralStorage)
+	return PodRequest{
+		MilliCPU:         cpu.MilliValue(),
+		Memory:           memory.Value(),
+		EphemeralStorage: storage.Value(),
+	}
+}
+
+// Add 累加 o 的资源，用于计算多个 pod 的资源之和
+func (r *PodRequest) Add(o PodRequest) {
+	r.MilliCPU += o.MilliCPU
+	r.Memory += o.Memory
+	r.EphemeralStorage += o.EphemeralStorage
+}
+
+func (r *PodRequest) max(o PodRequest) {
+	if o.MilliCPU > r.MilliCPU {
+		r.MilliCPU = o.MilliCPU
+	}
+	if o.Memory > r.Memory {
+		r.Memory = o.Memory
+	}
+	if o.EphemeralStorage > r.EphemeralStorage {
+		r.EphemeralStorage = o.EphemeralStorage
+	}
+}
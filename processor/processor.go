@@ -3,7 +3,6 @@ package processor
 import (
 	"log"
 	"math"
-	"sync/atomic"
 	"systeminfoagent/model"
 )
 
@@ -14,10 +13,11 @@ func debugLogF(s1 string, s2 ...interface{}) {
 // Processor 用于在打分的时候计算相关指标的分数
 // 返回 rawscore 和 weight
 // 同时给出计算平均值和方差的接口
+// processor 本身不保存任何可修改的配置，打分时使用的配置由 ProcessorConfig 传入
+// PodRequest 为待调度 pod 的资源需求，processor 可以据此计算放置 pod 之后的剩余资源
 // extraWeight: 在计算的时候会 / 100
 type Processor interface {
-	Score(*model.NodeFullMetric) (float64, float64)
-	ExtraWeight(int32)
+	Score(*model.NodeFullMetric, ProcessorConfig, PodRequest) (float64, float64)
 	N(*model.NodeInfoRecord)
 	Even(*model.NodeInfoRecord)
 	Variance(*model.NodeInfoRecord)
@@ -30,62 +30,135 @@ const (
 	TMEMORYPROCESSOR
 	TDISKUSAGEPROCESSOR
 	TNETWORKPROCESSOR
+	TLOADPROCESSOR
+	TPRESSUREPROCESSOR
+	TDISKIOPROCESSOR
+	TSOCKETPROCESSOR
 )
 
-var defaultextraweight int32 = 100
-
-var ProcessorMap map[ProcessorType]Processor = map[ProcessorType]Processor{
-	TCPUPROCESSOR:       &CPUProcessor{extraWeight: defaultextraweight},
-	TMEMORYPROCESSOR:    &MemoryProcessor{extraWeight: defaultextraweight},
-	TDISKUSAGEPROCESSOR: &DiskUsageProcessor{extraWeight: defaultextraweight},
-	TNETWORKPROCESSOR:   &NetworkProcessor{MaxRxPerSecond: 1 << 20, extraWeight: defaultextraweight},
+var processors = map[ProcessorType]Processor{
+	TCPUPROCESSOR:       &CPUProcessor{},
+	TMEMORYPROCESSOR:    &MemoryProcessor{},
+	TDISKUSAGEPROCESSOR: &DiskUsageProcessor{},
+	TNETWORKPROCESSOR:   &NetworkProcessor{},
+	TLOADPROCESSOR:      &LoadProcessor{},
+	TPRESSUREPROCESSOR:  &PressureProcessor{},
+	TDISKIOPROCESSOR:    &DiskIOProcessor{},
+	TSOCKETPROCESSOR:    &SocketProcessor{},
 }
 
-type ProcessorMapV struct {
-	processor Processor
-	postFunc  func(ProcessorType, float64, float64)
+// UpdateStatistics 在 record 中追加了新的数据之后，更新各个指标的数量、平均值和方差
+func UpdateStatistics(record *model.NodeInfoRecord) {
+	for _, processor := range processors {
+		processor.N(record)
+		processor.Even(record)
+		processor.Variance(record)
+	}
 }
 
-type ScoreProcessor struct {
-	processorMap map[ProcessorType]Processor
+// ProcessorScore 单个 processor 的打分结果
+type ProcessorScore struct {
+	Name     string  `json:"name"`
+	RawScore float64 `json:"raw_score"`
+	Weight   float64 `json:"weight"`
 }
 
-func NewScoreProcessor() *ScoreProcessor {
-	return &ScoreProcessor{
-		processorMap: ProcessorMap,
-	}
+// Explanation 一次打分的详细过程
+type Explanation struct {
+	NodeID        string           `json:"node_id"`
+	ConfigVersion uint64           `json:"config_version"`
+	Scoring       ScoringVersion   `json:"scoring"`
+	Profile       string           `json:"profile,omitempty"`
+	Rule          string           `json:"rule,omitempty"`
+	Pod           PodRequest       `json:"pod"`
+	Score         float64          `json:"score"`
+	TotalWeight   float64          `json:"total_weight"`
+	Processors    []ProcessorScore `json:"processors"`
 }
 
-func (sp *ScoreProcessor) Score(nfm *model.NodeFullMetric, postFuncs ...func(ProcessorType, float64, float64)) (float64, float64) {
-	var totalScore, totalWeight float64
-	// TODO: 查询需要使用到的 processor 以及设置的 weight
-	for processorType, processor := range sp.processorMap {
-		score, weight := processor.Score(nfm)
-		totalScore += score
-		totalWeight += weight
-		for _, fn := range postFuncs {
-			fn(processorType, score, weight)
+type ScoreProcessor struct{}
+
+func NewScoreProcessor() *ScoreProcessor {
+	return &ScoreProcessor{}
+}
+
+// Score 使用 config 这一份配置为节点打分，返回合并之后的分数以及权重之和
+// ScoringV1 为各项分数之和除以权重之和，ScoringV2 为按权重的加权平均值
+// pod 为待调度 pod 的资源需求，为零值时只根据节点当前的状态打分
+func (sp *ScoreProcessor) Score(nfm *model.NodeFullMetric, config *Config, pod PodRequest) (float64, float64) {
+	explanation := sp.Explain(nfm, config, pod)
+	return explanation.Score, explanation.TotalWeight
+}
+
+// Explain 与 Score 相同，但是返回每个 processor 的分数和权重
+func (sp *ScoreProcessor) Explain(nfm *model.NodeFullMetric, config *Config, pod PodRequest) *Explanation {
+	explanation := &Explanation{
+		NodeID:        nfm.NodeInfo.ID,
+		ConfigVersion: config.Version,
+		Scoring:       config.Scoring,
+		Profile:       config.Profile,
+		Rule:          config.Rule,
+		Pod:           pod,
+	}
+	var totalScore float64
+	for _, processorType := range config.types() {
+		processorConfig := config.Processors[processorType]
+		if !processorConfig.Enabled {
+			continue
+		}
+		score, weight := processors[processorType].Score(nfm, processorConfig, pod)
+		if config.Scoring == ScoringV2 {
+			totalScore += score * weight
+		} else {
+			totalScore += score
 		}
+		explanation.TotalWeight += weight
+		explanation.Processors = append(explanation.Processors, ProcessorScore{
+			Name:     processorType.String(),
+			RawScore: score,
+			Weight:   weight,
+		})
 	}
-	return totalScore / totalWeight, totalWeight
-}
-
-type CPUProcessor struct {
-	extraWeight int32
+	if explanation.TotalWeight > 0 {
+		explanation.Score = totalScore / explanation.TotalWeight
+	}
+	return explanation
 }
 
-func (cp *CPUProcessor) ExtraWeight(w int32) {
-	atomic.StoreInt32(&cp.extraWeight, w)
-}
+type CPUProcessor struct{}
 
-func (cp *CPUProcessor) Score(nfm *model.NodeFullMetric) (float64, float64) {
+func (*CPUProcessor) Score(nfm *model.NodeFullMetric, config ProcessorConfig, _ PodRequest) (float64, float64) {
 	raw := nfm.RawMetric.CPU
-	rawScore := (float64(raw.Idle) / float64(raw.System+raw.User+raw.Idle)) * 100.0
-	weight := calWeight(nfm.Statistics.CPU) * float64(cp.extraWeight) / 100.0
+	rawScore := cpuIdlePercent(raw, config.Params[ParamMode])
+	rawScore *= 1 - coreImbalancePenalty(raw, config)
+	weight := calWeight(nfm.Statistics.CPU) * float64(config.ExtraWeight) / 100.0
 	debugLogF("[CPU] %s\t%.2f\t%.2f", nfm.NodeInfo.ID, rawScore, weight)
 	return rawScore, weight
 }
 
+// cpuIdlePercent 按照 mode 计算空闲时间的百分比
+func cpuIdlePercent(raw model.CPU, mode float64) float64 {
+	switch mode {
+	case CPUModeLegacy:
+		return (float64(raw.Idle) / float64(raw.System+raw.User+raw.Idle)) * 100.0
+	case CPUModeStealBusy:
+		// iowait 时 CPU 实际上是空闲的，可以运行其他任务
+		return (float64(raw.Idle+raw.IOWait) / float64(raw.Total())) * 100.0
+	default:
+		return (float64(raw.Idle) / float64(raw.Total())) * 100.0
+	}
+}
+
+// coreImbalancePenalty 各个 CPU 使用率的标准差达到 max_core_stddev 时扣掉全部分数
+// max_core_stddev 为 0 或者 agent 没有上报 per-core 数据时不扣分
+func coreImbalancePenalty(raw model.CPU, config ProcessorConfig) float64 {
+	max := config.Params[ParamMaxCoreStdDev]
+	if max <= 0 || raw.PerCore == nil {
+		return 0
+	}
+	return math.Min(raw.PerCore.StdDev/max, 1)
+}
+
 func (*CPUProcessor) N(record *model.NodeInfoRecord) {
 	idx := len(record.Metrics) - 1
 	if len(record.Metrics) == 0 {
@@ -142,22 +215,42 @@ func (*CPUProcessor) Variance(record *model.NodeInfoRecord) {
 	record.Metrics[idx].Statistics.CPU.Variance = calVariance(prevVariance, currIdle, prevMean, currMean, n)
 }
 
-type MemoryProcessor struct {
-	extraWeight int32
-}
-
-func (mp *MemoryProcessor) ExtraWeight(w int32) {
-	atomic.StoreInt32(&mp.extraWeight, w)
-}
+type MemoryProcessor struct{}
 
-func (mp *MemoryProcessor) Score(nfm *model.NodeFullMetric) (float64, float64) {
+func (*MemoryProcessor) Score(nfm *model.NodeFullMetric, config ProcessorConfig, pod PodRequest) (float64, float64) {
 	raw := nfm.RawMetric.Memory
-	rawScore := (float64(raw.Free) / float64(raw.Total)) * 100.0
-	weight := calWeight(nfm.Statistics.Memory) * float64(mp.extraWeight) / 100.0
+	// 放置 pod 之后剩余的内存，低于下限时认为 pod 放不下
+	// pod 没有请求内存时不检查下限，只根据节点当前的状态打分
+	free := memoryFree(raw, config) - float64(pod.Memory)
+	rawScore := (free / float64(raw.Total)) * 100.0
+	if free <= 0 || pod.Memory > 0 && rawScore < config.Params[ParamMinFreePercent] {
+		rawScore = 0
+	}
+	if config.Params[ParamMode] == MemoryModeAvailable {
+		rawScore *= 1 - swapPenalty(raw, config)
+	}
+	weight := calWeight(nfm.Statistics.Memory) * float64(config.ExtraWeight) / 100.0
 	debugLogF("[memory] %s\t%.2f\t%.2f", nfm.NodeInfo.ID, rawScore, weight)
 	return rawScore, weight
 }
 
+// memoryFree 按照 mode 参数计算节点剩余的内存
+func memoryFree(raw model.Memory, config ProcessorConfig) float64 {
+	if config.Params[ParamMode] == MemoryModeAvailable {
+		return float64(raw.AvailableBytes())
+	}
+	return float64(raw.Free)
+}
+
+// swapPenalty 每秒换入换出的页数达到 max_swap_pages_per_second 时扣掉全部分数
+func swapPenalty(raw model.Memory, config ProcessorConfig) float64 {
+	max := config.Params[ParamMaxSwapPagesPerSecond]
+	if max <= 0 {
+		return 0
+	}
+	return math.Min(float64(raw.SwapIn+raw.SwapOut)/max, 1)
+}
+
 func (*MemoryProcessor) N(record *model.NodeInfoRecord) {
 	idx := len(record.Metrics) - 1
 	if len(record.Metrics) == 0 {
@@ -214,18 +307,22 @@ func (*MemoryProcessor) Variance(record *model.NodeInfoRecord) {
 	record.Metrics[idx].Statistics.Memory.Variance = calVariance(prevVariance, currFree, prevMean, currMean, n)
 }
 
-type DiskUsageProcessor struct {
-	extraWeight int32
-}
+type DiskUsageProcessor struct{}
 
-func (dp *DiskUsageProcessor) ExtraWeight(w int32) {
-	atomic.StoreInt32(&dp.extraWeight, w)
-}
-
-func (dup *DiskUsageProcessor) Score(nfm *model.NodeFullMetric) (float64, float64) {
+func (*DiskUsageProcessor) Score(nfm *model.NodeFullMetric, config ProcessorConfig, pod PodRequest) (float64, float64) {
 	raw := nfm.RawMetric.Disk
-	rawScore := (float64(raw.Free) / float64(raw.Size)) * 100.0
-	weight := calWeight(nfm.Statistics.Disk) * float64(dup.extraWeight) / 100.0
+	// 放置 pod 之后剩余的磁盘空间，低于下限时认为 pod 放不下
+	// pod 没有请求临时存储时不检查下限
+	free := float64(raw.Free) - float64(pod.EphemeralStorage)
+	rawScore := (free / float64(raw.Size)) * 100.0
+	if free <= 0 || pod.EphemeralStorage > 0 && free < config.Params[ParamMinFreeBytes] {
+		rawScore = 0
+	}
+	// inode 耗尽时即使还有空间也无法创建文件，按照字节和 inode 中较差的一项打分
+	if raw.Inodes > 0 {
+		rawScore = math.Min(rawScore, float64(raw.InodesFree)/float64(raw.Inodes)*100.0)
+	}
+	weight := calWeight(nfm.Statistics.Disk) * float64(config.ExtraWeight) / 100.0
 	debugLogF("[diskusage] %s\t%.2f\t%.2f", nfm.NodeInfo.ID, rawScore, weight)
 	return rawScore, weight
 }
@@ -286,23 +383,47 @@ func (*DiskUsageProcessor) Variance(record *model.NodeInfoRecord) {
 	record.Metrics[idx].Statistics.Disk.Variance = calVariance(prevVariance, currFree, prevMean, currMean, n)
 }
 
-type NetworkProcessor struct {
-	MaxRxPerSecond float64
-	extraWeight    int32
-}
+type NetworkProcessor struct{}
 
-func (np *NetworkProcessor) ExtraWeight(w int32) {
-	atomic.StoreInt32(&np.extraWeight, w)
-}
-
-func (np *NetworkProcessor) Score(nfm *model.NodeFullMetric) (float64, float64) {
+func (*NetworkProcessor) Score(nfm *model.NodeFullMetric, config ProcessorConfig, _ PodRequest) (float64, float64) {
 	raw := nfm.RawMetric.Network
-	rawScore := ((np.MaxRxPerSecond - float64(raw.RxBytes)) / np.MaxRxPerSecond) * 100.0
-	weight := calWeight(nfm.Statistics.Network) * float64(np.extraWeight) / 100.0
+	maxRx, maxTx := config.Params[ParamMaxRxPerSecond], config.Params[ParamMaxTxPerSecond]
+	if raw.SpeedMbps > 0 && config.Params[ParamUseLinkSpeed] != 0 {
+		// 全双工网卡收发方向各自可以达到协商速率，配置为 0 的方向表示不参与打分，保持不变
+		speed := float64(raw.SpeedMbps) * 1000 * 1000 / 8
+		if maxRx > 0 {
+			maxRx = speed
+		}
+		if maxTx > 0 {
+			maxTx = speed
+		}
+	}
+	rawScore := 100.0
+	if maxRx > 0 {
+		rawScore = ((maxRx - float64(raw.RxBytes)) / maxRx) * 100.0
+	}
+	if maxTx > 0 {
+		rawScore = math.Min(rawScore, ((maxTx-float64(raw.TxBytes))/maxTx)*100.0)
+	}
+	if rawScore < 0 {
+		rawScore = 0
+	}
+	rawScore *= 1 - networkErrorPenalty(raw, config)
+	weight := calWeight(nfm.Statistics.Network) * float64(config.ExtraWeight) / 100.0
 	debugLogF("[network] %s\t%.2f\t%.2f", nfm.NodeInfo.ID, rawScore, weight)
 	return rawScore, weight
 }
 
+// networkErrorPenalty 每秒的错误和丢包数之和达到 max_errors_per_second 时扣掉全部分数
+func networkErrorPenalty(raw model.Network, config ProcessorConfig) float64 {
+	max := config.Params[ParamMaxErrorsPerSecond]
+	if max <= 0 {
+		return 0
+	}
+	errors := raw.RxErrors + raw.TxErrors + raw.RxDropped + raw.TxDropped
+	return math.Min(float64(errors)/max, 1)
+}
+
 func (*NetworkProcessor) N(record *model.NodeInfoRecord) {
 	idx := len(record.Metrics) - 1
 	if len(record.Metrics) == 0 {
@@ -359,6 +480,321 @@ func (*NetworkProcessor) Variance(record *model.NodeInfoRecord) {
 	record.Metrics[idx].Statistics.Network.Variance = calVariance(prevVariance, currRx, prevMean, currMean, n)
 }
 
+// LoadProcessor 根据每个 CPU 上的 1 分钟平均负载打分，比一秒内的 idle 更平滑，
+// 也能反映出多核节点上排队等待 CPU 的情况
+type LoadProcessor struct{}
+
+func (*LoadProcessor) Score(nfm *model.NodeFullMetric, config ProcessorConfig, _ PodRequest) (float64, float64) {
+	raw := nfm.RawMetric.Load
+	// 旧版本的 agent 不上报负载，不参与打分
+	maxLoadPerCore := config.Params[ParamMaxLoadPerCore]
+	if !raw.Valid || maxLoadPerCore <= 0 {
+		return 0, 0
+	}
+	rawScore := ((maxLoadPerCore - raw.LoadPerCore()) / maxLoadPerCore) * 100.0
+	if rawScore < 0 {
+		rawScore = 0
+	}
+	weight := calWeight(nfm.Statistics.Load) * float64(config.ExtraWeight) / 100.0
+	debugLogF("[load] %s\t%.2f\t%.2f", nfm.NodeInfo.ID, rawScore, weight)
+	return rawScore, weight
+}
+
+func (*LoadProcessor) N(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.Load.N = 1
+		return
+	}
+	if !record.Metrics[idx].RawMetric.Load.Valid {
+		record.Metrics[idx].Statistics.Load.N = record.Metrics[idx-1].Statistics.Load.N
+		return
+	}
+	record.Metrics[idx].Statistics.Load.N = record.Metrics[idx-1].Statistics.Load.N + 1
+}
+
+func (*LoadProcessor) Even(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.Load.Mean = record.Metrics[idx].RawMetric.Load.LoadPerCore()
+		return
+	}
+	prevMean := record.Metrics[idx-1].Statistics.Load.Mean
+	n := float64(record.Metrics[idx-1].Statistics.Load.N) + 1
+	currLoad := record.Metrics[idx].RawMetric.Load.LoadPerCore()
+	if !record.Metrics[idx].RawMetric.Load.Valid {
+		record.Metrics[idx].Statistics.Load.Mean = prevMean
+		return
+	}
+	record.Metrics[idx].Statistics.Load.Mean = calEven(prevMean, currLoad, n)
+}
+
+func (*LoadProcessor) Variance(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.Load.Variance = 0
+		return
+	}
+	prevVariance := record.Metrics[idx-1].Statistics.Load.Variance
+	n := float64(record.Metrics[idx-1].Statistics.Load.N) + 1
+	currLoad := record.Metrics[idx].RawMetric.Load.LoadPerCore()
+	prevMean := record.Metrics[idx-1].Statistics.Load.Mean
+	currMean := record.Metrics[idx].Statistics.Load.Mean
+	if !record.Metrics[idx].RawMetric.Load.Valid {
+		record.Metrics[idx].Statistics.Load.Variance = prevVariance
+		return
+	}
+	record.Metrics[idx].Statistics.Load.Variance = calVariance(prevVariance, currLoad, prevMean, currMean, n)
+}
+
+// PressureProcessor 根据 PSI 打分，cpu、memory 和 io 中停顿最严重的一项决定分数
+// 空闲资源多但是仍然在争抢的节点（例如内存回收或者 io 排队）会被扣分
+type PressureProcessor struct{}
+
+func (*PressureProcessor) Score(nfm *model.NodeFullMetric, config ProcessorConfig, _ PodRequest) (float64, float64) {
+	raw := nfm.RawMetric.Pressure
+	// 内核不支持 PSI 时不参与打分
+	maxPressure := config.Params[ParamMaxPressurePercent]
+	if !raw.Valid || maxPressure <= 0 {
+		return 0, 0
+	}
+	rawScore := ((maxPressure - raw.MaxSome10()) / maxPressure) * 100.0
+	if rawScore < 0 {
+		rawScore = 0
+	}
+	weight := calWeight(nfm.Statistics.Pressure) * float64(config.ExtraWeight) / 100.0
+	debugLogF("[pressure] %s\t%.2f\t%.2f", nfm.NodeInfo.ID, rawScore, weight)
+	return rawScore, weight
+}
+
+func (*PressureProcessor) N(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.Pressure.N = 1
+		return
+	}
+	if !record.Metrics[idx].RawMetric.Pressure.Valid {
+		record.Metrics[idx].Statistics.Pressure.N = record.Metrics[idx-1].Statistics.Pressure.N
+		return
+	}
+	record.Metrics[idx].Statistics.Pressure.N = record.Metrics[idx-1].Statistics.Pressure.N + 1
+}
+
+func (*PressureProcessor) Even(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.Pressure.Mean = record.Metrics[idx].RawMetric.Pressure.MaxSome10()
+		return
+	}
+	prevMean := record.Metrics[idx-1].Statistics.Pressure.Mean
+	n := float64(record.Metrics[idx-1].Statistics.Pressure.N) + 1
+	currPressure := record.Metrics[idx].RawMetric.Pressure.MaxSome10()
+	if !record.Metrics[idx].RawMetric.Pressure.Valid {
+		record.Metrics[idx].Statistics.Pressure.Mean = prevMean
+		return
+	}
+	record.Metrics[idx].Statistics.Pressure.Mean = calEven(prevMean, currPressure, n)
+}
+
+func (*PressureProcessor) Variance(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.Pressure.Variance = 0
+		return
+	}
+	prevVariance := record.Metrics[idx-1].Statistics.Pressure.Variance
+	n := float64(record.Metrics[idx-1].Statistics.Pressure.N) + 1
+	currPressure := record.Metrics[idx].RawMetric.Pressure.MaxSome10()
+	prevMean := record.Metrics[idx-1].Statistics.Pressure.Mean
+	currMean := record.Metrics[idx].Statistics.Pressure.Mean
+	if !record.Metrics[idx].RawMetric.Pressure.Valid {
+		record.Metrics[idx].Statistics.Pressure.Variance = prevVariance
+		return
+	}
+	record.Metrics[idx].Statistics.Pressure.Variance = calVariance(prevVariance, currPressure, prevMean, currMean, n)
+}
+
+// DiskIOProcessor 根据最繁忙的块设备的 %util 和平均 await 打分，两者中较低的分数生效
+// 与 DiskUsageProcessor 不同，关心的是磁盘是否繁忙而不是剩余空间
+type DiskIOProcessor struct{}
+
+func (*DiskIOProcessor) Score(nfm *model.NodeFullMetric, config ProcessorConfig, _ PodRequest) (float64, float64) {
+	busiest, ok := nfm.RawMetric.Disk.BusiestDevice()
+	// 旧版本的 agent 不上报设备的 I/O 情况，不参与打分
+	if !ok {
+		return 0, 0
+	}
+	rawScore := 100.0 - busiest.Util
+	if maxAwait := config.Params[ParamMaxAwaitMs]; maxAwait > 0 {
+		rawScore = math.Min(rawScore, (maxAwait-busiest.AwaitMs)/maxAwait*100.0)
+	}
+	if rawScore < 0 {
+		rawScore = 0
+	}
+	weight := calWeight(nfm.Statistics.DiskIO) * float64(config.ExtraWeight) / 100.0
+	debugLogF("[diskio] %s\t%.2f\t%.2f", nfm.NodeInfo.ID, rawScore, weight)
+	return rawScore, weight
+}
+
+// diskUtil 最繁忙的设备的 %util，用于统计平均值和方差
+func diskUtil(metric model.NodeMetric) (float64, bool) {
+	busiest, ok := metric.Disk.BusiestDevice()
+	return busiest.Util, ok
+}
+
+func (*DiskIOProcessor) N(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.DiskIO.N = 1
+		return
+	}
+	if _, ok := diskUtil(record.Metrics[idx].RawMetric); !ok {
+		record.Metrics[idx].Statistics.DiskIO.N = record.Metrics[idx-1].Statistics.DiskIO.N
+		return
+	}
+	record.Metrics[idx].Statistics.DiskIO.N = record.Metrics[idx-1].Statistics.DiskIO.N + 1
+}
+
+func (*DiskIOProcessor) Even(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	currUtil, ok := diskUtil(record.Metrics[idx].RawMetric)
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.DiskIO.Mean = currUtil
+		return
+	}
+	prevMean := record.Metrics[idx-1].Statistics.DiskIO.Mean
+	n := float64(record.Metrics[idx-1].Statistics.DiskIO.N) + 1
+	if !ok {
+		record.Metrics[idx].Statistics.DiskIO.Mean = prevMean
+		return
+	}
+	record.Metrics[idx].Statistics.DiskIO.Mean = calEven(prevMean, currUtil, n)
+}
+
+func (*DiskIOProcessor) Variance(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.DiskIO.Variance = 0
+		return
+	}
+	prevVariance := record.Metrics[idx-1].Statistics.DiskIO.Variance
+	n := float64(record.Metrics[idx-1].Statistics.DiskIO.N) + 1
+	currUtil, ok := diskUtil(record.Metrics[idx].RawMetric)
+	prevMean := record.Metrics[idx-1].Statistics.DiskIO.Mean
+	currMean := record.Metrics[idx].Statistics.DiskIO.Mean
+	if !ok {
+		record.Metrics[idx].Statistics.DiskIO.Variance = prevVariance
+		return
+	}
+	record.Metrics[idx].Statistics.DiskIO.Variance = calVariance(prevVariance, currUtil, prevMean, currMean, n)
+}
+
+// SocketProcessor TIME_WAIT、conntrack 表或者临时端口接近上限时扣分
+// 使用率低于 penalty_start_percent 时不扣分，之后线性下降，达到上限时为 0
+type SocketProcessor struct{}
+
+func (*SocketProcessor) Score(nfm *model.NodeFullMetric, config ProcessorConfig, _ PodRequest) (float64, float64) {
+	raw := nfm.RawMetric.Sockets
+	// 旧版本的 agent 不上报 socket 数据，不参与打分
+	if !raw.Valid {
+		return 0, 0
+	}
+	usage := raw.MaxUsagePercent()
+	start := config.Params[ParamPenaltyStartPercent]
+	rawScore := 100.0
+	if usage >= 100 {
+		rawScore = 0
+	} else if usage > start {
+		rawScore = (100 - usage) / (100 - start) * 100.0
+	}
+	weight := calWeight(nfm.Statistics.Sockets) * float64(config.ExtraWeight) / 100.0
+	debugLogF("[sockets] %s\t%.2f\t%.2f", nfm.NodeInfo.ID, rawScore, weight)
+	return rawScore, weight
+}
+
+func (*SocketProcessor) N(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.Sockets.N = 1
+		return
+	}
+	if !record.Metrics[idx].RawMetric.Sockets.Valid {
+		record.Metrics[idx].Statistics.Sockets.N = record.Metrics[idx-1].Statistics.Sockets.N
+		return
+	}
+	record.Metrics[idx].Statistics.Sockets.N = record.Metrics[idx-1].Statistics.Sockets.N + 1
+}
+
+func (*SocketProcessor) Even(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.Sockets.Mean = record.Metrics[idx].RawMetric.Sockets.MaxUsagePercent()
+		return
+	}
+	prevMean := record.Metrics[idx-1].Statistics.Sockets.Mean
+	n := float64(record.Metrics[idx-1].Statistics.Sockets.N) + 1
+	currUsage := record.Metrics[idx].RawMetric.Sockets.MaxUsagePercent()
+	if !record.Metrics[idx].RawMetric.Sockets.Valid {
+		record.Metrics[idx].Statistics.Sockets.Mean = prevMean
+		return
+	}
+	record.Metrics[idx].Statistics.Sockets.Mean = calEven(prevMean, currUsage, n)
+}
+
+func (*SocketProcessor) Variance(record *model.NodeInfoRecord) {
+	idx := len(record.Metrics) - 1
+	if len(record.Metrics) == 0 {
+		return
+	}
+	if len(record.Metrics) == 1 {
+		record.Metrics[idx].Statistics.Sockets.Variance = 0
+		return
+	}
+	prevVariance := record.Metrics[idx-1].Statistics.Sockets.Variance
+	n := float64(record.Metrics[idx-1].Statistics.Sockets.N) + 1
+	currUsage := record.Metrics[idx].RawMetric.Sockets.MaxUsagePercent()
+	prevMean := record.Metrics[idx-1].Statistics.Sockets.Mean
+	currMean := record.Metrics[idx].Statistics.Sockets.Mean
+	if !record.Metrics[idx].RawMetric.Sockets.Valid {
+		record.Metrics[idx].Statistics.Sockets.Variance = prevVariance
+		return
+	}
+	record.Metrics[idx].Statistics.Sockets.Variance = calVariance(prevVariance, currUsage, prevMean, currMean, n)
+}
+
 func calWeight(ms model.MetricStatistics) float64 {
 	if ms.Mean == 0 || ms.Variance == 0 {
 		return 1
@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"math"
+	"testing"
+
+	"systeminfoagent/model"
+)
+
+func TestNetworkLinkSpeed(t *testing.T) {
+	const mib = 1 << 20
+	// 1000Mb/s 的网卡每秒可以收发 125000000 字节
+	const linkBytes = 125000000.0
+	cases := []struct {
+		name         string
+		maxRx, maxTx float64
+		useLinkSpeed float64
+		speedMbps    uint64
+		rx, tx       uint64
+		want         float64
+	}{
+		{"configured limits", mib, mib, 0, 1000, mib / 2, mib / 4, 50},
+		{"link speed", mib, mib, 1, 1000, linkBytes / 2, linkBytes / 4, 50},
+		{"link speed unknown", mib, mib, 1, 0, mib / 2, mib / 4, 50},
+		{"tx opt-out", mib, 0, 1, 1000, linkBytes / 4, linkBytes * 2, 75},
+		{"rx opt-out", 0, mib, 1, 1000, linkBytes * 2, linkBytes / 4, 75},
+		{"both opt-out", 0, 0, 1, 1000, linkBytes * 2, linkBytes * 2, 100},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := ProcessorConfig{
+				Enabled:     true,
+				ExtraWeight: 100,
+				Params: map[string]float64{
+					ParamMaxRxPerSecond: c.maxRx,
+					ParamMaxTxPerSecond: c.maxTx,
+					ParamUseLinkSpeed:   c.useLinkSpeed,
+				},
+			}
+			nfm := &model.NodeFullMetric{RawMetric: model.NodeMetric{Network: model.Network{
+				Valid:     true,
+				RxBytes:   c.rx,
+				TxBytes:   c.tx,
+				SpeedMbps: c.speedMbps,
+			}}}
+			score, _ := (&NetworkProcessor{}).Score(nfm, config, PodRequest{})
+			if math.Abs(score-c.want) > 1e-9 {
+				t.Errorf("score = %v, want %v", score, c.want)
+			}
+		})
+	}
+}
+
+// 默认配置下 network processor 只按接收字节数打分
+func TestDefaultNetworkScoring(t *testing.T) {
+	resetConfig(t)
+	nfm := &model.NodeFullMetric{RawMetric: model.NodeMetric{Network: model.Network{
+		Valid:     true,
+		RxBytes:   1 << 18,
+		TxBytes:   1 << 30,
+		RxErrors:  1000,
+		SpeedMbps: 1000,
+	}}}
+	score, _ := (&NetworkProcessor{}).Score(nfm, Current().Processors[TNETWORKPROCESSOR], PodRequest{})
+	if math.Abs(score-75) > 1e-9 {
+		t.Errorf("score = %v, want 75", score)
+	}
+}
+
+func TestDefaultCPUModeLegacy(t *testing.T) {
+	resetConfig(t)
+	config := Current().Processors[TCPUPROCESSOR]
+	if mode := config.Params[ParamMode]; mode != CPUModeLegacy {
+		t.Fatalf("default cpu mode = %v, want %v", mode, CPUModeLegacy)
+	}
+	// steal 和 iowait 不影响默认的打分
+	nfm := &model.NodeFullMetric{RawMetric: model.NodeMetric{CPU: model.CPU{
+		Valid: true, User: 20, System: 20, Idle: 60, IOWait: 50, Steal: 50,
+	}}}
+	score, _ := (&CPUProcessor{}).Score(nfm, config, PodRequest{})
+	if math.Abs(score-60) > 1e-9 {
+		t.Errorf("score = %v, want 60", score)
+	}
+}
+
+func TestDefaultMemoryModeFree(t *testing.T) {
+	resetConfig(t)
+	config := Current().Processors[TMEMORYPROCESSOR]
+	if mode := config.Params[ParamMode]; mode != MemoryModeFree {
+		t.Fatalf("default memory mode = %v, want %v", mode, MemoryModeFree)
+	}
+	// 默认按 MemFree 打分，page cache 和 swap 活动都不影响分数
+	nfm := &model.NodeFullMetric{RawMetric: model.NodeMetric{Memory: model.Memory{
+		Valid: true, Total: 1000, Free: 400, Available: 800, SwapIn: 5000, SwapOut: 5000,
+	}}}
+	score, _ := (&MemoryProcessor{}).Score(nfm, config, PodRequest{})
+	if math.Abs(score-40) > 1e-9 {
+		t.Errorf("score = %v, want 40", score)
+	}
+}
+
+func TestZeroPodRequestIgnoresFloors(t *testing.T) {
+	resetConfig(t)
+	config := Current()
+	// MemFree 只有 2%，其余都是 page cache；磁盘只剩 512MiB
+	nfm := &model.NodeFullMetric{RawMetric: model.NodeMetric{
+		Memory: model.Memory{Valid: true, Total: 1000, Free: 20, Available: 900},
+		Disk:   model.Disk{Valid: true, Size: 1 << 30, Free: 1 << 29},
+	}}
+	score, _ := (&MemoryProcessor{}).Score(nfm, config.Processors[TMEMORYPROCESSOR], PodRequest{})
+	if math.Abs(score-2) > 1e-9 {
+		t.Errorf("memory score = %v, want 2", score)
+	}
+	score, _ = (&DiskUsageProcessor{}).Score(nfm, config.Processors[TDISKUSAGEPROCESSOR], PodRequest{})
+	if math.Abs(score-50) > 1e-9 {
+		t.Errorf("disk score = %v, want 50", score)
+	}
+	if overloaded, reason := Overloaded(nfm, config, PodRequest{}, PodRequest{}); overloaded {
+		t.Errorf("overloaded with zero pod request: %s", reason)
+	}
+
+	// pod 请求了资源时仍然检查下限
+	pod := PodRequest{Memory: 10, EphemeralStorage: 1}
+	if score, _ := (&MemoryProcessor{}).Score(nfm, config.Processors[TMEMORYPROCESSOR], pod); score != 0 {
+		t.Errorf("memory score with request = %v, want 0", score)
+	}
+	if score, _ := (&DiskUsageProcessor{}).Score(nfm, config.Processors[TDISKUSAGEPROCESSOR], pod); score != 0 {
+		t.Errorf("disk score with request = %v, want 0", score)
+	}
+	if overloaded, _ := Overloaded(nfm, config, pod, PodRequest{}); !overloaded {
+		t.Errorf("not overloaded with pod request")
+	}
+}
+
+func TestOptInProcessorsDisabledByDefault(t *testing.T) {
+	resetConfig(t)
+	for _, processorType := range []ProcessorType{TLOADPROCESSOR, TPRESSUREPROCESSOR, TDISKIOPROCESSOR, TSOCKETPROCESSOR} {
+		if Current().Processors[processorType].Enabled {
+			t.Errorf("processor %s should be disabled by default", processorType)
+		}
+	}
+	for _, processorType := range []ProcessorType{TCPUPROCESSOR, TMEMORYPROCESSOR, TDISKUSAGEPROCESSOR, TNETWORKPROCESSOR} {
+		if !Current().Processors[processorType].Enabled {
+			t.Errorf("processor %s should be enabled by default", processorType)
+		}
+	}
+}
+
+func TestOverloadedLoadAndPressure(t *testing.T) {
+	resetConfig(t)
+	enabled := true
+	config, err := UpdateSettings(map[string]SettingsUpdate{
+		TLOADPROCESSOR.String():     {Enabled: &enabled},
+		TPRESSUREPROCESSOR.String(): {Enabled: &enabled},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := PodRequest{MilliCPU: 1000}
+	cases := []struct {
+		name       string
+		load       float64
+		pressure   float64
+		freed      int64
+		overloaded bool
+	}{
+		{"idle", 1, 0, 0, false},
+		// 4 个 CPU 上负载为 7，放置 1 核的 pod 之后每个 CPU 上的负载达到 2
+		{"load", 7, 0, 0, true},
+		{"load freed", 7, 0, 1000, false},
+		{"pressure", 1, 50, 1000, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			nfm := &model.NodeFullMetric{RawMetric: model.NodeMetric{
+				Load:     model.Load{Valid: true, Load1: c.load, CPUs: 4},
+				Pressure: model.Pressure{Valid: true, IO: model.ResourcePressure{Some: model.PressureStat{Avg10: c.pressure}}},
+			}}
+			if overloaded, reason := Overloaded(nfm, config, pod, PodRequest{MilliCPU: c.freed}); overloaded != c.overloaded {
+				t.Errorf("overloaded = %v (%s), want %v", overloaded, reason, c.overloaded)
+			}
+		})
+	}
+}
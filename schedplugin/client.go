@@ -0,0 +1,95 @@
+package schedplugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"systeminfoagent/model"
+	"systeminfoagent/policy"
+	"time"
+)
+
+// ErrNoMetric 节点没有数据
+var ErrNoMetric = fmt.Errorf("no metric")
+
+// MetricsClient 从 master 获取节点数据和打分配置
+type MetricsClient interface {
+	// LatestMetric 返回节点最新的数据，没有数据时返回 ErrNoMetric
+	LatestMetric(ctx context.Context, nodeName string) (*model.NodeFullMetric, error)
+	// LatestMetrics 返回所有节点最新的数据，按照 k8s 节点名索引
+	LatestMetrics(ctx context.Context) (map[string]*model.NodeFullMetric, error)
+	// Policy 返回 master 当前的 processor 配置和 profile 规则
+	Policy(ctx context.Context) (*policy.Snapshot, error)
+}
+
+// HTTPClient 通过 master 的 HTTP 接口获取数据
+type HTTPClient struct {
+	masterAddr string
+	token      string
+	client     *http.Client
+}
+
+// NewHTTPClient masterAddr 例如 http://10.211.55.52:8080，token 为 master 开启认证时使用的 read token，
+// client 为 nil 时使用默认配置
+func NewHTTPClient(masterAddr, token string, client *http.Client) *HTTPClient {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &HTTPClient{
+		masterAddr: strings.TrimSuffix(masterAddr, "/"),
+		token:      token,
+		client:     client,
+	}
+}
+
+func (c *HTTPClient) LatestMetric(ctx context.Context, nodeName string) (*model.NodeFullMetric, error) {
+	var metric model.NodeFullMetric
+	if err := c.get(ctx, "/api/v1/metrics/"+url.PathEscape(nodeName), &metric); err != nil {
+		return nil, err
+	}
+	return &metric, nil
+}
+
+func (c *HTTPClient) LatestMetrics(ctx context.Context) (map[string]*model.NodeFullMetric, error) {
+	var metrics map[string]*model.NodeFullMetric
+	if err := c.get(ctx, "/api/v1/metrics", &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+func (c *HTTPClient) Policy(ctx context.Context) (*policy.Snapshot, error) {
+	var snapshot policy.Snapshot
+	if err := c.get(ctx, "/api/v1/policy", &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (c *HTTPClient) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.masterAddr+path, nil)
+	if err != nil {
+		return fmt.Errorf("gen http request: %v", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("get %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNoMetric
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %s: unexpected status %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("get %s: decode: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Package kubeplugin 把 schedplugin.Plugin 适配为 kube-scheduler framework 的插件，
+// 在编译 kube-scheduler 时注册：
+//
+//	command := app.NewSchedulerCommand(app.WithPlugin(kubeplugin.Name, kubeplugin.New))
+//
+// 然后在 KubeSchedulerConfiguration 中启用 PreFilter、Filter、PreScore 和 Score，并在 pluginConfig 中设置 Args。
+//
+// 本包是单独的 module，只有编译 scheduler 时才需要 k8s.io/kubernetes，master 和 agent 不依赖它。
+package kubeplugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"systeminfoagent/schedplugin"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+)
+
+// Name 插件在 scheduler 配置中使用的名称
+const Name = schedplugin.Name
+
+// stateKey PreFilter 或 PreScore 中获取的数据在 framework.CycleState 中的 key
+const stateKey framework.StateKey = Name
+
+// Args 插件的参数
+type Args struct {
+	// MasterAddr master 的地址，例如 http://10.211.55.52:8080
+	MasterAddr string `json:"masterAddr"`
+	// TokenFile master 开启认证时使用的 read token 所在的文件
+	TokenFile string `json:"tokenFile"`
+	// TimeoutSeconds 访问 master 的超时时间，为 0 时为 5 秒
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// PolicyTTLSeconds 从 master 获取的配置的缓存时间，为 0 时为 30 秒
+	PolicyTTLSeconds int `json:"policyTTLSeconds"`
+	// MetricsTTLSeconds 从 master 获取的节点数据的缓存时间，过期后在后台刷新，默认为 5 秒
+	MetricsTTLSeconds int `json:"metricsTTLSeconds"`
+}
+
+// Plugin framework 插件，所有打分逻辑都在 schedplugin.Plugin 中
+type Plugin struct {
+	plugin *schedplugin.Plugin
+}
+
+var (
+	_ framework.PreFilterPlugin = &Plugin{}
+	_ framework.FilterPlugin    = &Plugin{}
+	_ framework.PreScorePlugin  = &Plugin{}
+	_ framework.ScorePlugin     = &Plugin{}
+	_ framework.ScoreExtensions = &Plugin{}
+
+	_ frameworkruntime.PluginFactory = New
+)
+
+// New 插件的 PluginFactory
+func New(obj runtime.Object, _ framework.Handle) (framework.Plugin, error) {
+	args := Args{TimeoutSeconds: 5, PolicyTTLSeconds: 30, MetricsTTLSeconds: 5}
+	if err := frameworkruntime.DecodeInto(obj, &args); err != nil {
+		return nil, fmt.Errorf("decode %s args: %v", Name, err)
+	}
+	if args.MasterAddr == "" {
+		return nil, fmt.Errorf("%s args: masterAddr is required", Name)
+	}
+	if args.TimeoutSeconds <= 0 || args.PolicyTTLSeconds < 0 || args.MetricsTTLSeconds < 0 {
+		return nil, fmt.Errorf("%s args: timeoutSeconds must be positive, policyTTLSeconds and metricsTTLSeconds must not be negative", Name)
+	}
+	var token string
+	if args.TokenFile != "" {
+		data, err := os.ReadFile(args.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s args: read token file: %v", Name, err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	client := schedplugin.NewHTTPClient(args.MasterAddr, token, &http.Client{Timeout: time.Duration(args.TimeoutSeconds) * time.Second})
+	return &Plugin{plugin: schedplugin.New(client, time.Duration(args.PolicyTTLSeconds)*time.Second, time.Duration(args.MetricsTTLSeconds)*time.Second)}, nil
+}
+
+func (p *Plugin) Name() string {
+	return Name
+}
+
+// stateData 保存在 framework.CycleState 中的 schedplugin.CycleState，创建之后不再修改，Clone 不需要复制
+type stateData struct {
+	state *schedplugin.CycleState
+}
+
+func (s *stateData) Clone() framework.StateData {
+	return s
+}
+
+// readState 没有经过 PreFilter 和 PreScore 时返回 nil，schedplugin.Plugin 会自己获取数据
+func readState(state *framework.CycleState) *schedplugin.CycleState {
+	if state == nil {
+		return nil
+	}
+	data, err := state.Read(stateKey)
+	if err != nil {
+		return nil
+	}
+	s, ok := data.(*stateData)
+	if !ok {
+		return nil
+	}
+	return s.state
+}
+
+// asStatus v1.20 的 framework.AsStatus 不接受 nil
+func asStatus(err error) *framework.Status {
+	if err == nil {
+		return nil
+	}
+	return framework.AsStatus(err)
+}
+
+func (p *Plugin) PreFilter(ctx context.Context, state *framework.CycleState, pod *v1.Pod) *framework.Status {
+	s, err := p.plugin.PreFilter(ctx, pod)
+	if err != nil {
+		return asStatus(err)
+	}
+	state.Write(stateKey, &stateData{s})
+	return nil
+}
+
+func (p *Plugin) PreFilterExtensions() framework.PreFilterExtensions {
+	return nil
+}
+
+// Filter 放置 pod 之后内存或者磁盘低于阈值的节点返回 Unschedulable
+func (p *Plugin) Filter(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
+	node := nodeInfo.Node()
+	if node == nil {
+		return framework.NewStatus(framework.Error, "node not found")
+	}
+	ok, reason, err := p.plugin.Filter(ctx, readState(state), pod, node.Name)
+	if err != nil {
+		return asStatus(err)
+	}
+	if !ok {
+		return framework.NewStatus(framework.Unschedulable, reason)
+	}
+	return nil
+}
+
+func (p *Plugin) PreScore(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodes []*v1.Node) *framework.Status {
+	s, err := p.plugin.PreScore(ctx, readState(state), pod, nodes)
+	if err != nil {
+		return asStatus(err)
+	}
+	state.Write(stateKey, &stateData{s})
+	return nil
+}
+
+func (p *Plugin) Score(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) (int64, *framework.Status) {
+	score, err := p.plugin.Score(ctx, readState(state), pod, nodeName)
+	return score, asStatus(err)
+}
+
+func (p *Plugin) ScoreExtensions() framework.ScoreExtensions {
+	return p
+}
+
+func (p *Plugin) NormalizeScore(ctx context.Context, state *framework.CycleState, pod *v1.Pod, scores framework.NodeScoreList) *framework.Status {
+	nodeScores := make([]schedplugin.NodeScore, len(scores))
+	for i, s := range scores {
+		nodeScores[i] = schedplugin.NodeScore{Name: s.Name, Score: s.Score}
+	}
+	if err := p.plugin.NormalizeScore(ctx, readState(state), pod, nodeScores); err != nil {
+		return asStatus(err)
+	}
+	for i := range scores {
+		scores[i].Score = nodeScores[i].Score
+	}
+	return nil
+}
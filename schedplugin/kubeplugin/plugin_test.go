@@ -0,0 +1,161 @@
+package kubeplugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"systeminfoagent/model"
+	"systeminfoagent/policy"
+	"systeminfoagent/processor"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/defaultbinder"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/queuesort"
+	frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+)
+
+func testMetric(idle, memoryFree uint64) *model.NodeFullMetric {
+	return &model.NodeFullMetric{RawMetric: model.NodeMetric{
+		CPU:    model.CPU{Valid: true, User: 100 - idle, Idle: idle},
+		Memory: model.Memory{Valid: true, Total: 10 << 30, Free: memoryFree, Available: memoryFree},
+	}}
+}
+
+// startMaster 模拟 master 的查询接口，返回 master 地址和 /api/v1/metrics 的访问次数
+func startMaster(t *testing.T, metrics map[string]*model.NodeFullMetric) (string, *int32) {
+	t.Helper()
+	var calls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		json.NewEncoder(w).Encode(metrics)
+	})
+	mux.HandleFunc("/api/v1/policy", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(policy.Snapshot{Config: processor.Current().Status()})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL, &calls
+}
+
+func newFramework(t *testing.T, args string) (framework.Framework, error) {
+	t.Helper()
+	// framework 要求启用 QueueSort 和 Bind 插件，使用 scheduler 默认的插件
+	registry := frameworkruntime.Registry{
+		queuesort.Name:     queuesort.New,
+		defaultbinder.Name: defaultbinder.New,
+	}
+	if err := registry.Register(Name, New); err != nil {
+		t.Fatal(err)
+	}
+	enabled := &config.PluginSet{Enabled: []config.Plugin{{Name: Name, Weight: 1}}}
+	plugins := &config.Plugins{
+		QueueSort: &config.PluginSet{Enabled: []config.Plugin{{Name: queuesort.Name}}},
+		Bind:      &config.PluginSet{Enabled: []config.Plugin{{Name: defaultbinder.Name}}},
+		PreFilter: enabled,
+		Filter:    enabled,
+		PreScore:  enabled,
+		Score:     enabled,
+	}
+	pluginConfig := []config.PluginConfig{{Name: Name, Args: &runtime.Unknown{Raw: []byte(args), ContentType: runtime.ContentTypeJSON}}}
+	return frameworkruntime.NewFramework(registry, plugins, pluginConfig)
+}
+
+func testNode(name string) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func TestFramework(t *testing.T) {
+	addr, calls := startMaster(t, map[string]*model.NodeFullMetric{
+		"node-1": testMetric(90, 8<<30),
+		"node-2": testMetric(20, 8<<30),
+		"node-3": testMetric(90, 1<<30),
+	})
+	fwk, err := newFramework(t, `{"masterAddr": "`+addr+`"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "uid"},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")}},
+		}}},
+	}
+	ctx := context.Background()
+	state := framework.NewCycleState()
+	if status := fwk.RunPreFilterPlugins(ctx, state, pod); !status.IsSuccess() {
+		t.Fatalf("prefilter: %v", status.AsError())
+	}
+
+	var feasible []*v1.Node
+	for _, name := range []string{"node-1", "node-2", "node-3", "node-4"} {
+		nodeInfo := framework.NewNodeInfo()
+		nodeInfo.SetNode(testNode(name))
+		statuses := fwk.RunFilterPlugins(ctx, state, pod, nodeInfo)
+		if status := statuses.Merge(); !status.IsSuccess() {
+			if !status.IsUnschedulable() {
+				t.Fatalf("filter %s: %v", name, status.AsError())
+			}
+			continue
+		}
+		feasible = append(feasible, nodeInfo.Node())
+	}
+	// node-3 放置 pod 之后内存不足，没有数据的 node-4 不过滤
+	if len(feasible) != 3 || feasible[0].Name != "node-1" || feasible[1].Name != "node-2" || feasible[2].Name != "node-4" {
+		t.Fatalf("feasible nodes: %v", feasible)
+	}
+
+	if status := fwk.RunPreScorePlugins(ctx, state, pod, feasible); !status.IsSuccess() {
+		t.Fatalf("prescore: %v", status.AsError())
+	}
+	scores, status := fwk.RunScorePlugins(ctx, state, pod, feasible)
+	if !status.IsSuccess() {
+		t.Fatalf("score: %v", status.AsError())
+	}
+	byNode := map[string]int64{}
+	for _, s := range scores[Name] {
+		byNode[s.Name] = s.Score
+		if s.Score < 0 || s.Score > framework.MaxNodeScore {
+			t.Errorf("%s: score %d out of range", s.Name, s.Score)
+		}
+	}
+	if byNode["node-1"] <= byNode["node-2"] {
+		t.Errorf("idle node-1 (%d) should score higher than busy node-2 (%d)", byNode["node-1"], byNode["node-2"])
+	}
+	if byNode["node-4"] != 0 {
+		t.Errorf("node without metric: score %d, want 0", byNode["node-4"])
+	}
+	// 整个调度周期只访问一次 master
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("metrics fetched %d times, want 1", n)
+	}
+}
+
+func TestNewArgs(t *testing.T) {
+	tests := []struct {
+		args string
+		ok   bool
+	}{
+		{`{"masterAddr": "http://127.0.0.1:8080"}`, true},
+		{`{"masterAddr": "http://127.0.0.1:8080", "timeoutSeconds": 1, "policyTTLSeconds": 0}`, true},
+		{`{}`, false},
+		{`{"masterAddr": "http://127.0.0.1:8080", "timeoutSeconds": -1}`, false},
+		{`{"masterAddr": "http://127.0.0.1:8080", "tokenFile": "/nonexistent"}`, false},
+		{`{"masterAddr": 1}`, false},
+	}
+	for _, tt := range tests {
+		_, err := newFramework(t, tt.args)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.args, err, tt.ok)
+		}
+	}
+}
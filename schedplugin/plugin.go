@@ -0,0 +1,246 @@
+// Package schedplugin 是可以直接编译进 kube-scheduler 的打分插件，打分方式与 master 的 HTTP extender 完全相同：
+// 使用同一个 processor.ScoreProcessor、同一套 profile 规则以及 master 中保存的节点数据，
+// 只是省去了每个调度周期一次的 HTTP 调用和 json 序列化。
+//
+// 每个调度周期只在 PreFilter（没有启用 Filter 时为 PreScore）中从 master 获取一次所有节点的数据，
+// 保存在 CycleState 中，Filter 和 Score 不再访问 master。
+//
+// 为了不让 master 和 agent 依赖 k8s.io/kubernetes，插件实现的是本包中与 scheduler framework 一一对应的接口，
+// *framework.CycleState 换成了 *CycleState，*framework.Status 换成了普通的 error。
+// 实现 framework 接口、可以在 kube-scheduler 中注册的适配见单独的 module schedplugin/kubeplugin。
+package schedplugin
+
+import (
+	"context"
+	"log"
+	"sync"
+	"systeminfoagent/model"
+	"systeminfoagent/policy"
+	"systeminfoagent/processor"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Name 插件在 scheduler 配置中使用的名称
+const Name = "SystemInfoScore"
+
+// MaxNodeScore 与 framework.MaxNodeScore 相同
+// Score 返回的是 extender prioritize 的原始分数，不保证在 [0, MaxNodeScore] 之间，由 NormalizeScore 缩放
+const MaxNodeScore int64 = 100
+
+// NodeScore 对应 framework.NodeScore
+type NodeScore struct {
+	Name  string
+	Score int64
+}
+
+// Plugin 打分插件
+type Plugin struct {
+	client         MetricsClient
+	policyTTL      time.Duration
+	scoreProcessor *processor.ScoreProcessor
+
+	lock      sync.Mutex
+	snapshot  *policy.Snapshot
+	config    *processor.Config
+	fetchedAt time.Time
+
+	metricsTTL       time.Duration
+	metricsLock      sync.Mutex
+	metrics          map[string]*model.NodeFullMetric
+	metricsFetchedAt time.Time
+	refreshing       bool
+}
+
+// New policyTTL 为从 master 获取的配置的缓存时间，同一个调度周期内的节点会使用同一份配置
+// metricsTTL 为节点数据的缓存时间，过期之后在后台刷新，调度周期中不等待 master
+func New(client MetricsClient, policyTTL, metricsTTL time.Duration) *Plugin {
+	return &Plugin{
+		client:         client,
+		policyTTL:      policyTTL,
+		metricsTTL:     metricsTTL,
+		scoreProcessor: processor.NewScoreProcessor(),
+	}
+}
+
+func (p *Plugin) Name() string {
+	return Name
+}
+
+// CycleState 一个调度周期内所有节点共用的数据，创建之后不再修改，可以被并发读取
+type CycleState struct {
+	metrics map[string]*model.NodeFullMetric
+	config  *processor.Config
+	pod     processor.PodRequest
+}
+
+// PreFilterPlugin 对应 framework.PreFilterPlugin
+type PreFilterPlugin interface {
+	Name() string
+	PreFilter(ctx context.Context, pod *v1.Pod) (*CycleState, error)
+}
+
+// FilterPlugin 对应 framework.FilterPlugin
+type FilterPlugin interface {
+	Name() string
+	Filter(ctx context.Context, state *CycleState, pod *v1.Pod, nodeName string) (bool, string, error)
+}
+
+// PreScorePlugin 对应 framework.PreScorePlugin，state 为 nil 时返回新创建的 CycleState
+type PreScorePlugin interface {
+	Name() string
+	PreScore(ctx context.Context, state *CycleState, pod *v1.Pod, nodes []*v1.Node) (*CycleState, error)
+}
+
+// ScorePlugin 对应 framework.ScorePlugin
+type ScorePlugin interface {
+	Name() string
+	Score(ctx context.Context, state *CycleState, pod *v1.Pod, nodeName string) (int64, error)
+	ScoreExtensions() ScoreExtensions
+}
+
+// ScoreExtensions 对应 framework.ScoreExtensions
+type ScoreExtensions interface {
+	NormalizeScore(ctx context.Context, state *CycleState, pod *v1.Pod, scores []NodeScore) error
+}
+
+var (
+	_ PreFilterPlugin = &Plugin{}
+	_ FilterPlugin    = &Plugin{}
+	_ PreScorePlugin  = &Plugin{}
+	_ ScorePlugin     = &Plugin{}
+	_ ScoreExtensions = &Plugin{}
+)
+
+// PreFilter 取出缓存的节点数据和 pod 使用的配置，供同一个调度周期中的 Filter 和 Score 使用
+// 获取数据失败时不影响调度，所有节点都按照没有数据处理
+func (p *Plugin) PreFilter(ctx context.Context, pod *v1.Pod) (*CycleState, error) {
+	snapshot, base := p.policy(ctx)
+	return &CycleState{
+		metrics: p.latestMetrics(ctx),
+		config:  snapshot.Policy.Select(pod, base),
+		pod:     processor.NewPodRequest(pod),
+	}, nil
+}
+
+// PreScore 没有启用 PreFilter 时获取数据，否则直接使用 PreFilter 中获取的数据
+func (p *Plugin) PreScore(ctx context.Context, state *CycleState, pod *v1.Pod, _ []*v1.Node) (*CycleState, error) {
+	if state != nil {
+		return state, nil
+	}
+	return p.PreFilter(ctx, pod)
+}
+
+// stateOrNew 兼容没有经过 PreFilter 和 PreScore 的调用，此时每次调用都会读取一次缓存
+func (p *Plugin) stateOrNew(ctx context.Context, state *CycleState, pod *v1.Pod) *CycleState {
+	if state != nil {
+		return state
+	}
+	state, _ = p.PreFilter(ctx, pod)
+	return state
+}
+
+// Score 与 extender 的 prioritize 相同，没有数据的节点得分为 0
+func (p *Plugin) Score(ctx context.Context, state *CycleState, pod *v1.Pod, nodeName string) (int64, error) {
+	state = p.stateOrNew(ctx, state, pod)
+	metric, ok := state.metrics[nodeName]
+	if !ok {
+		return 0, nil
+	}
+	score, _ := p.scoreProcessor.Score(metric, state.config, state.pod)
+	return int64(score), nil
+}
+
+func (p *Plugin) ScoreExtensions() ScoreExtensions {
+	return p
+}
+
+// NormalizeScore Score 返回的是 extender 的原始分数，ScoringV1 下权重之和小于 1 时会超过 MaxNodeScore，
+// 这时按最高分等比例缩放到 [0, MaxNodeScore]，保持节点之间的排序与 extender 相同
+func (p *Plugin) NormalizeScore(_ context.Context, _ *CycleState, _ *v1.Pod, scores []NodeScore) error {
+	var max int64
+	for i := range scores {
+		if scores[i].Score < 0 {
+			scores[i].Score = 0
+		}
+		if scores[i].Score > max {
+			max = scores[i].Score
+		}
+	}
+	if max <= MaxNodeScore {
+		return nil
+	}
+	for i := range scores {
+		scores[i].Score = scores[i].Score * MaxNodeScore / max
+	}
+	return nil
+}
+
+// Filter 放置 pod 之后内存或者磁盘低于阈值的节点不可调度，返回是否可调度以及原因
+// 没有数据的节点不过滤
+func (p *Plugin) Filter(ctx context.Context, state *CycleState, pod *v1.Pod, nodeName string) (bool, string, error) {
+	state = p.stateOrNew(ctx, state, pod)
+	metric, ok := state.metrics[nodeName]
+	if !ok {
+		return true, "", nil
+	}
+	overloaded, reason := processor.Overloaded(metric, state.config, state.pod, processor.PodRequest{})
+	return !overloaded, reason, nil
+}
+
+// latestMetrics 返回缓存的节点数据，过期后在后台刷新，刷新完成之前继续使用旧的数据
+// 从来没有获取成功过时在调用方的 goroutine 中获取
+func (p *Plugin) latestMetrics(ctx context.Context) map[string]*model.NodeFullMetric {
+	p.metricsLock.Lock()
+	if !p.metricsFetchedAt.IsZero() {
+		if time.Since(p.metricsFetchedAt) >= p.metricsTTL && !p.refreshing {
+			p.refreshing = true
+			go p.refreshMetrics(context.Background())
+		}
+		metrics := p.metrics
+		p.metricsLock.Unlock()
+		return metrics
+	}
+	p.metricsLock.Unlock()
+	p.refreshMetrics(ctx)
+	p.metricsLock.Lock()
+	defer p.metricsLock.Unlock()
+	return p.metrics
+}
+
+// refreshMetrics 获取失败时保留旧的数据
+func (p *Plugin) refreshMetrics(ctx context.Context) {
+	metrics, err := p.client.LatestMetrics(ctx)
+	p.metricsLock.Lock()
+	defer p.metricsLock.Unlock()
+	p.refreshing = false
+	if err != nil {
+		log.Printf("[err] get metrics from master: %v", err)
+		return
+	}
+	p.metrics, p.metricsFetchedAt = metrics, time.Now()
+}
+
+// policy 返回缓存的配置，过期后重新获取；获取失败时继续使用旧的配置，
+// 从来没有获取成功过时使用默认配置
+func (p *Plugin) policy(ctx context.Context) (*policy.Snapshot, *processor.Config) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.snapshot != nil && time.Since(p.fetchedAt) < p.policyTTL {
+		return p.snapshot, p.config
+	}
+	snapshot, err := p.client.Policy(ctx)
+	if err == nil {
+		var config *processor.Config
+		if config, err = snapshot.Config.Config(); err == nil {
+			p.snapshot, p.config, p.fetchedAt = snapshot, config, time.Now()
+			return p.snapshot, p.config
+		}
+	}
+	log.Printf("[err] get policy from master: %v", err)
+	if p.snapshot == nil {
+		return &policy.Snapshot{}, processor.Current()
+	}
+	return p.snapshot, p.config
+}
@@ -0,0 +1,283 @@
+package schedplugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"systeminfoagent/model"
+	"systeminfoagent/policy"
+	"systeminfoagent/processor"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeClient 记录访问 master 的次数
+type fakeClient struct {
+	lock         sync.Mutex
+	metrics      map[string]*model.NodeFullMetric
+	config       *processor.ConfigStatus // 为 nil 时返回当前的全局配置
+	err          error
+	metricCalls  int
+	metricsCalls int
+	policyCalls  int
+}
+
+func (f *fakeClient) LatestMetric(_ context.Context, nodeName string) (*model.NodeFullMetric, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.metricCalls++
+	if metric, ok := f.metrics[nodeName]; ok {
+		return metric, nil
+	}
+	return nil, ErrNoMetric
+}
+
+func (f *fakeClient) LatestMetrics(context.Context) (map[string]*model.NodeFullMetric, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.metricsCalls++
+	return f.metrics, f.err
+}
+
+func (f *fakeClient) Policy(context.Context) (*policy.Snapshot, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.policyCalls++
+	if f.config != nil {
+		return &policy.Snapshot{Config: f.config}, nil
+	}
+	return &policy.Snapshot{Config: processor.Current().Status()}, nil
+}
+
+func testMetric(idle, memoryFree uint64) *model.NodeFullMetric {
+	return &model.NodeFullMetric{RawMetric: model.NodeMetric{
+		CPU:    model.CPU{Valid: true, User: 100 - idle, Idle: idle},
+		Memory: model.Memory{Valid: true, Total: 10 << 30, Free: memoryFree, Available: memoryFree},
+	}}
+}
+
+func testPod(memory string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "uid"},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse(memory)}},
+		}}},
+	}
+}
+
+func TestCycleFetchesOnce(t *testing.T) {
+	client := &fakeClient{metrics: map[string]*model.NodeFullMetric{
+		"node-1": testMetric(90, 8<<30),
+		"node-2": testMetric(20, 8<<30),
+		"node-3": testMetric(90, 1<<30),
+	}}
+	p := New(client, time.Minute, time.Minute)
+	pod := testPod("1Gi")
+	ctx := context.Background()
+	state, err := p.PreFilter(ctx, pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := []string{"node-1", "node-2", "node-3", "node-4"}
+	var feasible []*v1.Node
+	for _, name := range nodes {
+		ok, reason, err := p.Filter(ctx, state, pod, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "node-3" {
+			if ok || reason == "" {
+				t.Errorf("node-3 should be filtered out, got %v %q", ok, reason)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s filtered out: %s", name, reason)
+		}
+		feasible = append(feasible, &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	state, err = p.PreScore(ctx, state, pod, feasible)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores := make([]NodeScore, 0, len(feasible))
+	for _, node := range feasible {
+		score, err := p.Score(ctx, state, pod, node.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scores = append(scores, NodeScore{Name: node.Name, Score: score})
+	}
+	if err := p.ScoreExtensions().NormalizeScore(ctx, state, pod, scores); err != nil {
+		t.Fatal(err)
+	}
+	if scores[0].Score <= scores[1].Score {
+		t.Errorf("idle node-1 should score higher than busy node-2: %+v", scores)
+	}
+	if scores[2].Score != 0 {
+		t.Errorf("node without metric should score 0: %+v", scores[2])
+	}
+	if client.metricsCalls != 1 || client.metricCalls != 0 || client.policyCalls != 1 {
+		t.Errorf("master was called %d/%d/%d times in one cycle, want 1/0/1", client.metricsCalls, client.metricCalls, client.policyCalls)
+	}
+}
+
+func TestPreScoreWithoutPreFilter(t *testing.T) {
+	client := &fakeClient{metrics: map[string]*model.NodeFullMetric{"node-1": testMetric(90, 8<<30)}}
+	p := New(client, time.Minute, time.Minute)
+	pod := testPod("1Gi")
+	state, err := p.PreScore(context.Background(), nil, pod, nil)
+	if err != nil || state == nil {
+		t.Fatalf("PreScore() = %v, %v", state, err)
+	}
+	if score, _ := p.Score(context.Background(), state, pod, "node-1"); score == 0 {
+		t.Error("node-1 should have a score")
+	}
+	if client.metricsCalls != 1 {
+		t.Errorf("LatestMetrics was called %d times, want 1", client.metricsCalls)
+	}
+}
+
+func TestPreFilterError(t *testing.T) {
+	client := &fakeClient{err: errors.New("connection refused")}
+	p := New(client, time.Minute, time.Minute)
+	pod := testPod("1Gi")
+	state, err := p.PreFilter(context.Background(), pod)
+	if err != nil {
+		t.Fatalf("master errors should not fail scheduling: %v", err)
+	}
+	if ok, _, _ := p.Filter(context.Background(), state, pod, "node-1"); !ok {
+		t.Error("nodes should not be filtered without metrics")
+	}
+	if score, _ := p.Score(context.Background(), state, pod, "node-1"); score != 0 {
+		t.Errorf("score = %d, want 0", score)
+	}
+}
+
+func TestHTTPClientLatestMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/metrics" || r.Header.Get("Authorization") != "Bearer read-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"node-1":{"raw_metric":{"cpu":{"valid":true,"idle":90}}}}`))
+	}))
+	defer server.Close()
+	metrics, err := NewHTTPClient(server.URL+"/", "read-token", nil).LatestMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metric, ok := metrics["node-1"]; !ok || !metric.RawMetric.CPU.Valid {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+}
+
+func TestMetricsRefreshedInBackground(t *testing.T) {
+	client := &fakeClient{metrics: map[string]*model.NodeFullMetric{"node-1": testMetric(90, 8<<30)}}
+	p := New(client, time.Minute, time.Hour)
+	pod := testPod("1Gi")
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := p.PreFilter(ctx, pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if client.metricsCalls != 1 {
+		t.Fatalf("LatestMetrics was called %d times in 3 cycles within the ttl, want 1", client.metricsCalls)
+	}
+
+	// 过期后仍然返回旧的数据，同时在后台刷新
+	p.metricsLock.Lock()
+	p.metricsFetchedAt = time.Now().Add(-2 * time.Hour)
+	p.metricsLock.Unlock()
+	client.lock.Lock()
+	client.metrics = map[string]*model.NodeFullMetric{"node-2": testMetric(90, 8<<30)}
+	client.lock.Unlock()
+	state, _ := p.PreFilter(ctx, pod)
+	if _, ok := state.metrics["node-1"]; !ok {
+		t.Error("stale snapshot should be used while refreshing")
+	}
+	for i := 0; i < 100; i++ {
+		state, _ = p.PreFilter(ctx, pod)
+		if _, ok := state.metrics["node-2"]; ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := state.metrics["node-2"]; !ok {
+		t.Error("snapshot was not refreshed in the background")
+	}
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.metricsCalls != 2 {
+		t.Errorf("LatestMetrics was called %d times, want 2", client.metricsCalls)
+	}
+}
+
+// ranking 按分数从高到低排列的节点名
+func ranking(scores []NodeScore) []string {
+	sorted := append([]NodeScore(nil), scores...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+	names := make([]string, len(sorted))
+	for i, score := range sorted {
+		names[i] = score.Name
+	}
+	return names
+}
+
+func TestRankingMatchesExtender(t *testing.T) {
+	// 只启用 cpu 和 memory，权重为 10 时权重之和为 0.2，ScoringV1 的分数远大于 100
+	status := processor.Current().Status()
+	for i := range status.Processors {
+		status.Processors[i].Weight = 10
+		status.Processors[i].Enabled = status.Processors[i].Name == "cpu" || status.Processors[i].Name == "memory"
+	}
+	config, err := status.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &fakeClient{config: status, metrics: map[string]*model.NodeFullMetric{
+		"node-1": testMetric(90, 8<<30),
+		"node-2": testMetric(20, 8<<30),
+		"node-3": testMetric(60, 5<<30),
+		"node-4": testMetric(10, 2<<30),
+	}}
+	pod := testPod("1Gi")
+	ctx := context.Background()
+	p := New(client, time.Minute, time.Minute)
+	state, err := p.PreScore(ctx, nil, pod, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var extender, plugin []NodeScore
+	for _, name := range []string{"node-1", "node-2", "node-3", "node-4"} {
+		// 与 master 的 prioritize 相同
+		score, _ := processor.NewScoreProcessor().Score(client.metrics[name], config, processor.NewPodRequest(pod))
+		extender = append(extender, NodeScore{Name: name, Score: int64(score)})
+		pluginScore, err := p.Score(ctx, state, pod, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plugin = append(plugin, NodeScore{Name: name, Score: pluginScore})
+	}
+	if err := p.ScoreExtensions().NormalizeScore(ctx, state, pod, plugin); err != nil {
+		t.Fatal(err)
+	}
+	for _, score := range plugin {
+		if score.Score < 0 || score.Score > MaxNodeScore {
+			t.Errorf("normalized score out of range: %+v", score)
+		}
+	}
+	if got, want := ranking(plugin), ranking(extender); !reflect.DeepEqual(got, want) {
+		t.Errorf("plugin ranking %v, extender ranking %v (plugin %+v, extender %+v)", got, want, plugin, extender)
+	}
+}
@@ -0,0 +1,145 @@
+// Package tlsutil master 和 agent 共用的 TLS 配置
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader 在证书或私钥文件被修改后自动重新加载，用于证书轮换时不需要重启进程
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	lock      sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// reloadCheckInterval 两次检查文件修改时间的最小间隔
+const reloadCheckInterval = time.Second
+
+// NewCertReloader 立即加载一次证书，失败时返回错误
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *CertReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return fmt.Errorf("stat certificate: %v", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %v", err)
+	}
+	r.cert, r.modTime = &cert, modTime
+	return nil
+}
+
+// Certificate 返回当前的证书，文件有变化时重新加载；加载失败时继续使用旧的证书
+func (r *CertReloader) Certificate() *tls.Certificate {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if time.Since(r.checkedAt) < reloadCheckInterval {
+		return r.cert
+	}
+	r.checkedAt = time.Now()
+	if modTime, err := r.latestModTime(); err == nil && !modTime.Equal(r.modTime) {
+		if err := r.reload(); err != nil {
+			log.Printf("[err] reload certificate %s: %v", r.certFile, err)
+		} else {
+			log.Printf("[info] reloaded certificate %s", r.certFile)
+		}
+	}
+	return r.cert
+}
+
+// GetCertificate 用于 tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// GetClientCertificate 用于 tls.Config.GetClientCertificate
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// LoadCertPool 从 PEM 文件中读取 CA 证书
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read ca file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("read ca file: no certificate found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// ServerConfig 服务端配置，clientCAFile 不为空时校验客户端提供的证书，
+// 但是不强制要求客户端提供证书，由具体的路由决定是否需要
+func ServerConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if clientCAFile != "" {
+		pool, err := LoadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+// ClientConfig 客户端配置，caFile 为空时使用系统的 CA，certFile 和 keyFile 不为空时提供客户端证书
+func ClientConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		reloader, err := NewCertReloader(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = reloader.GetClientCertificate
+	}
+	return config, nil
+}
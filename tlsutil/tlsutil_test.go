@@ -0,0 +1,189 @@
+package tlsutil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCerts 在临时目录中生成 ca、server 和 client 证书
+func testCerts(t *testing.T) (string, *KeyPair) {
+	t.Helper()
+	dir := t.TempDir()
+	ca, err := GenerateCA("test-ca", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.WriteFiles(dir, "ca"); err != nil {
+		t.Fatal(err)
+	}
+	server, err := GenerateCert(ca, "master", []string{"127.0.0.1", "localhost"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.WriteFiles(dir, "server"); err != nil {
+		t.Fatal(err)
+	}
+	client, err := GenerateCert(ca, "node-1", nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WriteFiles(dir, "client"); err != nil {
+		t.Fatal(err)
+	}
+	return dir, ca
+}
+
+// newTLSServer 返回的服务端在响应中写入客户端证书的 CN，没有经过校验的证书时为空
+func newTLSServer(t *testing.T, dir string) *httptest.Server {
+	t.Helper()
+	config, err := ServerConfig(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.VerifiedChains) > 0 {
+			io.WriteString(w, r.TLS.VerifiedChains[0][0].Subject.CommonName)
+		}
+	}))
+	// StartTLS 会加上 httptest 自带的证书，这里直接使用 config 提供的证书
+	server.Listener = tls.NewListener(server.Listener, config)
+	server.Start()
+	server.URL = strings.Replace(server.URL, "http://", "https://", 1)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func get(t *testing.T, config *tls.Config, url string) (string, error) {
+	t.Helper()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, _ := testCerts(t)
+	server := newTLSServer(t, dir)
+
+	config, err := ClientConfig(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn, err := get(t, config, server.URL); err != nil || cn != "node-1" {
+		t.Errorf("with client cert: %q %v", cn, err)
+	}
+
+	// 服务端不强制要求客户端证书
+	config, err = ClientConfig(filepath.Join(dir, "ca.crt"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn, err := get(t, config, server.URL); err != nil || cn != "" {
+		t.Errorf("without client cert: %q %v", cn, err)
+	}
+
+	// 不信任的 CA 签发的服务端证书
+	other, _ := testCerts(t)
+	config, err = ClientConfig(filepath.Join(other, "ca.crt"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, config, server.URL); err == nil {
+		t.Error("expected an error for an untrusted server certificate")
+	}
+
+	// 不信任的 CA 签发的客户端证书
+	config, err = ClientConfig(filepath.Join(dir, "ca.crt"), filepath.Join(other, "client.crt"), filepath.Join(other, "client.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, config, server.URL); err == nil {
+		t.Error("expected an error for an untrusted client certificate")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, ca := testCerts(t)
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := r.Certificate()
+
+	next, err := GenerateCert(ca, "master-rotated", []string{"127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := next.WriteFiles(dir, "server"); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 检查间隔内不重新加载
+	if r.Certificate() != before {
+		t.Error("reloaded within the check interval")
+	}
+	r.lock.Lock()
+	r.checkedAt = time.Time{}
+	r.lock.Unlock()
+	after := r.Certificate()
+	if after == before || !bytes.Equal(after.Certificate[0], pemDER(t, next.CertPEM)) {
+		t.Error("certificate was not reloaded")
+	}
+
+	// 加载失败时继续使用旧的证书
+	if err := os.WriteFile(certFile, []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future = future.Add(time.Minute)
+	if err := os.Chtimes(certFile, future, future); err != nil {
+		t.Fatal(err)
+	}
+	r.lock.Lock()
+	r.checkedAt = time.Time{}
+	r.lock.Unlock()
+	if r.Certificate() != after {
+		t.Error("broken certificate replaced the loaded one")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewCertReloader(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key")); err == nil {
+		t.Error("expected an error for missing files")
+	}
+	empty := filepath.Join(dir, "empty.crt")
+	if err := os.WriteFile(empty, []byte("not a pem"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCertPool(empty); err == nil || !strings.Contains(err.Error(), "no certificate found") {
+		t.Errorf("LoadCertPool() = %v", err)
+	}
+}
+
+func pemDER(t *testing.T, data []byte) []byte {
+	t.Helper()
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("no pem block")
+	}
+	return block.Bytes
+}